@@ -0,0 +1,24 @@
+//go:build exclude
+
+package testdata
+
+// Account is a struct whose tags are resolved by the dialects of their libraries
+// #tagsvar
+type Account struct {
+	ID       int    `json:"id"         yaml:"id"    gorm:"primaryKey"`
+	UserName string `json:",omitempty" yaml:""      gorm:"column:login;not null"`
+	Email    string `json:"-,"         yaml:"email" gorm:"type:varchar(255)"`
+	Password string `json:"-"          yaml:"-"     gorm:"-"`
+	Note     string `json:"note"       xml:",chardata"`
+	secret   string `json:"secret"     yaml:"secret" gorm:"secret"`
+}
+
+// Profile is a struct whose embedded and association fields are not gorm columns
+// #tagsvar:include:json,gorm
+type Profile struct {
+	ID      int       `json:"id"      gorm:"primaryKey"`
+	Account Account   `json:"account" gorm:"embedded;embeddedPrefix:account_"`
+	Friends []Account `json:"friends" gorm:"many2many:profile_friends"`
+	OwnerID int       `json:"owner"   gorm:"column:owner_id"`
+	Owner   Account   `json:"-"       gorm:"foreignKey:OwnerID"`
+}
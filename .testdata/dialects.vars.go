@@ -0,0 +1,82 @@
+// Code generated by tagsvar. DO NOT EDIT.
+
+//go:build exclude
+
+package testdata
+
+// File: ../../.testdata/dialects.go
+
+// Struct: Account
+// Account is a struct whose tags are resolved by the dialects of their libraries
+const (
+	// Tag: json
+	JsonAccountId       = "id"
+	JsonAccountUserName = "UserName"
+	JsonAccountEmail    = "-"
+	JsonAccountNote     = "note"
+
+	// Tag: yaml
+	YamlAccountId       = "id"
+	YamlAccountUserName = "username"
+	YamlAccountEmail    = "email"
+
+	// Tag: gorm
+	GormAccountId       = "id"
+	GormAccountUserName = "login"
+	GormAccountEmail    = "email"
+
+// Tag: xml
+
+)
+
+var (
+	// Tag: json
+	JsonAccountUserNameOptions = map[string]any{
+		"omitempty": nil,
+	}
+
+	// Tag: yaml
+
+	// Tag: gorm
+	GormAccountIdOptions = map[string]any{
+		"primaryKey": nil,
+	}
+	GormAccountUserNameOptions = map[string]any{
+		"column":   "login",
+		"not null": nil,
+	}
+	GormAccountEmailOptions = map[string]any{
+		"type": "varchar(255)",
+	}
+
+	// Tag: xml
+	XmlAccountNoteOptions = map[string]any{
+		"chardata": nil,
+	}
+)
+
+// Struct: Profile
+// Profile is a struct whose embedded and association fields are not gorm columns
+const (
+	// Tag: json
+	JsonProfileId      = "id"
+	JsonProfileAccount = "account"
+	JsonProfileFriends = "friends"
+	JsonProfileOwnerId = "owner"
+
+	// Tag: gorm
+	GormProfileId      = "id"
+	GormProfileOwnerId = "owner_id"
+)
+
+var (
+	// Tag: json
+
+	// Tag: gorm
+	GormProfileIdOptions = map[string]any{
+		"primaryKey": nil,
+	}
+	GormProfileOwnerIdOptions = map[string]any{
+		"column": "owner_id",
+	}
+)
@@ -1,5 +1,7 @@
 // Code generated by tagsvar. DO NOT EDIT.
 
+//go:build exclude
+
 package testdata
 
 // File: ../../.testdata/user.go
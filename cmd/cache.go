@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"github.com/go-mods/tagsvar/modules/cache"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/go-mods/tagsvar/modules/generator"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/rs/zerolog/log"
+	"path/filepath"
+	"sort"
+)
+
+// generateIncremental generates the variables files of the package directories that changed since the
+// previous generation
+// The package directories whose source files, configuration and variables files did not change are
+// skipped without being parsed
+func (o *genOptions) generateIncremental(p *parser.Parser, g *generator.Generator, dir fs.Target) {
+	c, err := cache.NewCache(config.C.CacheDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not find the cache directory")
+		return
+	}
+
+	// Info message
+	log.Info().Msgf("Parsing files in %s", dir.Dir)
+
+	// List the files to parse by package directory
+	files, err := fs.ListFiles(dir.Dir, dir.Recursive, fs.IsBuildFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not list files project files to parse")
+		return
+	}
+	packages := make(map[string][]string)
+	for _, file := range files {
+		packages[filepath.Dir(file)] = append(packages[filepath.Dir(file)], file)
+	}
+	pkgDirs := make([]string, 0, len(packages))
+	for pkgDir := range packages {
+		pkgDirs = append(pkgDirs, pkgDir)
+	}
+	sort.Strings(pkgDirs)
+
+	// Skip the package directories that did not change
+	keys := make(map[string]string)
+	skipped := make(map[string]bool)
+	changed := make([]string, 0, len(files))
+	for _, pkgDir := range pkgDirs {
+		key, err := cache.Key(packages[pkgDir])
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Could not read the files of %s", pkgDir)
+			return
+		}
+		upToDate, err := c.Lookup(pkgDir, key)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Could not read the cache of %s", pkgDir)
+			return
+		}
+		if upToDate {
+			log.Debug().Msgf("Skipping unchanged package %s", pkgDir)
+			skipped[pkgDir] = true
+			continue
+		}
+		keys[pkgDir] = key
+		changed = append(changed, packages[pkgDir]...)
+	}
+
+	// Parse and generate the changed package directories
+	parsedFiles, err := p.ParseFiles(changed)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not parse files")
+		return
+	}
+	outputs, err := g.Render(parsedFiles)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not generate variables files")
+		return
+	}
+	err = g.Write(outputs)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not generate variables files")
+		return
+	}
+
+	// Remove the variables files whose source does not yield any struct anymore
+	// The variables files of the skipped package directories are not orphaned, their sources did not change
+	existing, err := generatedFiles([]fs.Target{dir})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not list generated files")
+		return
+	}
+	candidates := make([]string, 0, len(existing))
+	for _, path := range existing {
+		if !skipped[filepath.Dir(generator.SourcePath(path))] {
+			candidates = append(candidates, path)
+		}
+	}
+	o.removeOrphans(g, parsedFiles, candidates)
+
+	// Record the state of the generated package directories
+	generated := make(map[string]map[string][]byte)
+	for _, output := range outputs {
+		pkgDir := filepath.Dir(string(output.Source))
+		if generated[pkgDir] == nil {
+			generated[pkgDir] = make(map[string][]byte)
+		}
+		generated[pkgDir][output.Path] = output.Code
+	}
+	for _, pkgDir := range pkgDirs {
+		if key, ok := keys[pkgDir]; ok {
+			if err = c.Store(pkgDir, key, generated[pkgDir]); err != nil {
+				log.Fatal().Err(err).Msgf("Could not write the cache of %s", pkgDir)
+				return
+			}
+		}
+	}
+
+	if len(skipped) > 0 {
+		log.Info().Msgf("Skipped %d unchanged packages", len(skipped))
+	}
+}
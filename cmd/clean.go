@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/go-mods/tagsvar/modules/config"
 	"github.com/go-mods/tagsvar/modules/fs"
-	"github.com/rs/zerolog"
+	"github.com/go-mods/tagsvar/modules/generator"
+	"github.com/go-mods/tagsvar/modules/parser"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
 )
 
@@ -13,6 +16,9 @@ import (
 type cleanOptions struct {
 	Dir         string
 	IsRecursive bool
+	DryRun      bool
+	Force       bool
+	Orphans     bool
 }
 
 // clean command
@@ -21,24 +27,24 @@ func newCleanCmd() *cobra.Command {
 	o := &cleanOptions{}
 
 	cleanCmd := &cobra.Command{
-		Use:     "clean",
+		Use:     "clean [packages]",
 		Aliases: []string{"c"},
 		Short:   "delete generated files",
-		PreRun: func(cmd *cobra.Command, args []string) {
-			if config.C.Silent {
-				log.Logger = log.Logger.Level(zerolog.Disabled)
-			} else if config.C.Verbose {
-				log.Logger = log.Logger.Level(zerolog.DebugLevel)
-			}
-		},
-		Run: o.clean,
+		PreRun:  loadConfig,
+		Args:    cobra.ArbitraryArgs,
+		Run:     o.clean,
 	}
 
 	// Add flags
 	cleanCmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "Delete generated files in the directory")
 	cleanCmd.Flags().BoolVarP(&o.IsRecursive, "recursive", "r", false, "Recursively delete generated files in all subdirectories")
-	cleanCmd.Flags().BoolVarP(&config.C.Verbose, "verbose", "v", false, "Print files being deleted")
-	cleanCmd.Flags().BoolVarP(&config.C.Silent, "silent", "s", false, "Do not print anything")
+	cleanCmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Print the files that would be deleted without deleting them")
+	cleanCmd.Flags().BoolVar(&o.Force, "force", false, "Delete the files matching the prefix and suffix even if they were not generated by tagsvar")
+	cleanCmd.Flags().BoolVar(&o.Orphans, "orphans", false, "Only delete the generated files whose source does not yield any struct anymore")
+	cleanCmd.Flags().String("include", config.C.Include, "Comma separated list of globs of the files to process")
+	cleanCmd.Flags().String("exclude", config.C.Exclude, "Comma separated list of globs of the files and directories to skip")
+	cleanCmd.Flags().BoolP("verbose", "v", false, "Print files being deleted")
+	cleanCmd.Flags().BoolP("silent", "s", false, "Do not print anything")
 
 	return cleanCmd
 }
@@ -47,33 +53,90 @@ func newCleanCmd() *cobra.Command {
 func (o *cleanOptions) clean(cmd *cobra.Command, args []string) {
 	var err error
 
-	// Get the working directory
-	o.Dir, err = fs.WorkDir(o.Dir)
+	// Get the directories to clean
+	dirs, err := targets(args, o.Dir, o.IsRecursive)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not get working directory")
+		log.Fatal().Err(err).Msg("Could not resolve the packages to clean")
 		return
 	}
 
-	// Info message
-	log.Info().Msgf("Cleaning directory %s", o.Dir)
+	// Each directory is cleaned with its own configuration
+	deleted, skipped := 0, 0
+	err = forEachScope(dirs, func(dir fs.Target) {
+		// Info message
+		log.Info().Msgf("Cleaning directory %s", dir.Dir)
+
+		// List files to delete
+		scope := []fs.Target{dir}
+		files, err := generatedFiles(scope)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not list generated files to delete")
+			return
+		}
 
-	// List files to delete
-	files, err := fs.ListFiles(o.Dir, o.IsRecursive, fs.IsGeneratedFile)
+		// Only keep the variables files whose source does not yield any struct anymore
+		if o.Orphans {
+			parsedFiles, err := parseTargets(parser.NewParser(), scope)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Could not list files project files to parse")
+				return
+			}
+			files, err = generator.NewGenerator().Orphans(parsedFiles, files)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Could not find orphaned variables files")
+				return
+			}
+		}
+
+		d, s := o.delete(cmd.OutOrStdout(), files)
+		deleted += d
+		skipped += s
+	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not list generated files to delete")
+		log.Fatal().Err(err).Msg("Could not load the configuration")
 		return
 	}
 
-	// Delete files
+	// Info message
+	// The level of the logger may have been changed by the configuration of the directories
+	setLogLevel()
+	if o.DryRun {
+		log.Info().Msgf("Would delete %d files, skipped %d files", deleted, skipped)
+		return
+	}
+	log.Info().Msgf("Finished cleaning %d files, skipped %d files", deleted, skipped)
+}
+
+// delete deletes the files and returns the number of deleted and skipped files
+// The files without the header of the generated files are skipped, unless forced
+func (o *cleanOptions) delete(out io.Writer, files []string) (deleted int, skipped int) {
 	for _, file := range files {
+		if !o.Force {
+			generated, err := generator.IsGenerated(file)
+			if err != nil {
+				log.Fatal().Err(err).Msgf("Could not read file %s", file)
+				return
+			}
+			if !generated {
+				log.Warn().Msgf("Skipping file not generated by tagsvar : %s", file)
+				skipped++
+				continue
+			}
+		}
+
+		if o.DryRun {
+			_, _ = fmt.Fprintln(out, file)
+			deleted++
+			continue
+		}
+
 		log.Debug().Msgf("Deleting file : %s", file)
-		err = os.Remove(file)
+		err := os.Remove(file)
 		if err != nil {
 			log.Fatal().Err(err).Msgf("Could not delete file %s", file)
 			return
 		}
+		deleted++
 	}
-
-	// Info message
-	log.Info().Msgf("Finished cleaning directory %s", o.Dir)
+	return deleted, skipped
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/go-mods/tagsvar/modules/generator"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCleanCmd(t *testing.T) {
+	var tests = []struct {
+		args    []string
+		output  []string
+		deleted []string
+	}{
+		{args: []string{"--dry-run"}, output: []string{"user.vars.go"}},
+		{args: []string{"--dry-run", "--force"}, output: []string{"manual.vars.go", "user.vars.go"}},
+		{args: []string{}, deleted: []string{"user.vars.go"}},
+		{args: []string{"--force"}, deleted: []string{"manual.vars.go", "user.vars.go"}},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		files := map[string]string{
+			"user.vars.go":   generator.Header + "\n\npackage models\n",
+			"manual.vars.go": "package models\n",
+		}
+		writeFiles(t, dir, files)
+
+		var out bytes.Buffer
+		cleanCmd := newCleanCmd()
+		cleanCmd.SetOut(&out)
+		cleanCmd.SetArgs(append([]string{"--dir", dir, "--silent"}, test.args...))
+		if err := cleanCmd.Execute(); err != nil {
+			t.Fatalf("clean %v error = %v", test.args, err)
+		}
+
+		var output []string
+		for _, line := range strings.Fields(out.String()) {
+			output = append(output, filepath.Base(line))
+		}
+		if strings.Join(output, ",") != strings.Join(test.output, ",") {
+			t.Errorf("clean %v printed %v, want %v", test.args, output, test.output)
+		}
+
+		for name := range files {
+			_, err := os.Stat(filepath.Join(dir, name))
+			deleted := os.IsNotExist(err)
+			if want := slices.Contains(test.deleted, name); deleted != want {
+				t.Errorf("clean %v deleted %s = %v, want %v", test.args, name, deleted, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// loadConfig loads the configuration of the working directory, overridden by the flags of the command
+func loadConfig(cmd *cobra.Command, _ []string) {
+	config.BindFlags(cmd.Flags())
+
+	dir, err := fs.WorkDir()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not get the working directory")
+		return
+	}
+	if err = config.Load(dir); err != nil {
+		log.Fatal().Err(err).Msg("Could not load the configuration")
+		return
+	}
+	setLogLevel()
+}
+
+// setLogLevel sets the level of the logger from the configuration
+func setLogLevel() {
+	switch {
+	case config.C.Silent:
+		log.Logger = log.Logger.Level(zerolog.Disabled)
+	case config.C.Verbose:
+		log.Logger = log.Logger.Level(zerolog.DebugLevel)
+	default:
+		log.Logger = log.Logger.Level(zerolog.InfoLevel)
+	}
+}
+
+// forEachScope calls the function for each directory with the configuration of the directory
+// The subdirectories of a recursive directory holding their own configuration file are
+// processed after it, with their own configuration
+func forEachScope(dirs []fs.Target, fn func(dir fs.Target)) error {
+	queue := append([]fs.Target(nil), dirs...)
+	processed := make(map[fs.Target]bool)
+
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if processed[dir] {
+			continue
+		}
+		processed[dir] = true
+
+		// Load the configuration of the directory
+		if err := config.Load(dir.Dir); err != nil {
+			return err
+		}
+		setLogLevel()
+
+		// Process the subdirectories with their own configuration separately
+		if dir.Recursive {
+			configDirs, err := fs.ConfigDirs(dir.Dir)
+			if err != nil {
+				return err
+			}
+			for _, configDir := range configDirs {
+				queue = append(queue, fs.Target{Dir: configDir, Recursive: true})
+			}
+		}
+
+		fn(dir)
+	}
+	return nil
+}
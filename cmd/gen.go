@@ -1,19 +1,29 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/go-mods/tagsvar/modules/config"
 	"github.com/go-mods/tagsvar/modules/fs"
 	"github.com/go-mods/tagsvar/modules/generator"
 	"github.com/go-mods/tagsvar/modules/parser"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"time"
 )
 
 // gen command options
 type genOptions struct {
 	Dir         string
 	IsRecursive bool
+	Check       bool
+	DryRun      bool
+	Diff        bool
+	KeepOrphans bool
+	Watch       bool
+	Interval    time.Duration
+	Debounce    time.Duration
 }
 
 // clean command
@@ -22,25 +32,51 @@ func newGenCmd() *cobra.Command {
 	o := &genOptions{}
 
 	genCmd := &cobra.Command{
-		Use:     "gen",
+		Use:     "gen [packages]",
 		Aliases: []string{"g"},
 		Short:   "todo",
 		Long:    "todo",
-		PreRun: func(cmd *cobra.Command, args []string) {
-			if config.C.Silent {
-				log.Logger = log.Logger.Level(zerolog.Disabled)
-			} else if config.C.Verbose {
-				log.Logger = log.Logger.Level(zerolog.DebugLevel)
-			}
-		},
-		Run: o.gen,
+		PreRun:  loadConfig,
+		Args:    cobra.ArbitraryArgs,
+		Run:     o.gen,
 	}
 
 	// Add flags
 	genCmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "Generate variables files for the directory")
 	genCmd.Flags().BoolVarP(&o.IsRecursive, "recursive", "r", false, "Generate variables files for all subdirectories")
-	genCmd.Flags().BoolVarP(&config.C.Verbose, "verbose", "v", false, "Print files being deleted")
-	genCmd.Flags().BoolVarP(&config.C.Silent, "silent", "s", false, "Do not print anything")
+	genCmd.Flags().BoolVar(&o.Check, "check", false, "Check that the variables files are up to date without writing them")
+	genCmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Print the variables files that would be written without writing them")
+	genCmd.Flags().BoolVar(&o.Diff, "diff", false, "Print a unified diff of the variables files without writing them")
+	genCmd.Flags().BoolVar(&o.KeepOrphans, "keep-orphans", false, "Warn about the orphaned variables files instead of deleting them")
+	genCmd.Flags().BoolVar(&o.Watch, "watch", false, "Keep running and regenerate the variables files when the source files change")
+	genCmd.Flags().DurationVar(&o.Interval, "interval", 500*time.Millisecond, "Polling interval of the source files with --watch")
+	genCmd.Flags().DurationVar(&o.Debounce, "debounce", 300*time.Millisecond, "Delay without any change before regenerating with --watch")
+	genCmd.Flags().String("template", config.C.Template, "Template file redefining the templates of the generated code")
+	genCmd.Flags().String("naming", config.C.Naming, "Template of the generated identifiers, with the .Tag, .Struct and .Field parts")
+	genCmd.Flags().String("mode", config.C.Mode, "Emission mode: flat (constants), namespace (a struct value per struct and tag) or both")
+	genCmd.Flags().Bool("helpers", config.C.Helpers, "Generate the lists, the maps and the lookup functions of the tag names")
+	genCmd.Flags().String("options", config.C.OptionsMode, "Emission mode of the tag options: map (strings), typed (inferred types) or struct (a struct type per tag)")
+	genCmd.Flags().String("options-schema", config.C.OptionsSchema, "Comma separated list of tag.option=type typing the tag options (ie: gorm.size=int)")
+	genCmd.Flags().Bool("initialisms", config.C.Initialisms, "Write the Go initialisms in upper case in the generated identifiers (ie: ID, JSON)")
+	genCmd.Flags().Bool("unexported", config.C.Unexported, "Generate unexported identifiers")
+	genCmd.Flags().Bool("disambiguate", config.C.Disambiguate, "Rename the colliding identifiers with a numeric suffix instead of failing")
+	genCmd.Flags().Bool("per-package", config.C.PerPackage, "Generate a single variables file per package instead of a file per source file")
+	genCmd.Flags().String("package-file", config.C.PackageFile, "Name of the variables file of a package generated with --per-package")
+	genCmd.Flags().String("out-dir", config.C.OutDir, "Directory of the variables files, relative to the directory of the source package (ie: fields)")
+	genCmd.Flags().String("out-package", config.C.OutPackage, "Package name of the variables files generated with --out-dir (defaults to the directory name)")
+	genCmd.Flags().Bool("cache", config.C.Cache, "Skip the packages whose sources, configuration and variables files did not change since the previous generation")
+	genCmd.Flags().String("cache-dir", config.C.CacheDir, "Directory of the cache (defaults to the tagsvar directory of the user cache directory)")
+	genCmd.Flags().Int("jobs", config.C.Jobs, "Number of files parsed and written concurrently (defaults to GOMAXPROCS)")
+	genCmd.Flags().String("tags", config.C.Tags, "Comma separated list of build tags used to select the files")
+	genCmd.Flags().String("goos", config.C.GOOS, "Target operating system used to select the files")
+	genCmd.Flags().String("goarch", config.C.GOARCH, "Target architecture used to select the files")
+	genCmd.Flags().String("backend", config.C.Backend, "Parser backend: ast (go/parser) or packages (type-checked with go/packages)")
+	genCmd.Flags().Bool("flatten", config.C.Flatten, "Flatten the embedded structs into the embedding struct")
+	genCmd.Flags().Bool("dialects", config.C.Dialects, "Apply the semantics of the well-known tag keys (json, gorm, ...) to the generated names")
+	genCmd.Flags().String("include", config.C.Include, "Comma separated list of globs of the files to process")
+	genCmd.Flags().String("exclude", config.C.Exclude, "Comma separated list of globs of the files and directories to skip")
+	genCmd.Flags().BoolP("verbose", "v", false, "Print files being deleted")
+	genCmd.Flags().BoolP("silent", "s", false, "Do not print anything")
 
 	return genCmd
 }
@@ -49,33 +85,165 @@ func newGenCmd() *cobra.Command {
 func (o *genOptions) gen(cmd *cobra.Command, args []string) {
 	var err error
 
-	// Get the working directory
-	o.Dir, err = fs.WorkDir(o.Dir)
+	// Get the directories to parse
+	dirs, err := targets(args, o.Dir, o.IsRecursive)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not get working directory")
+		log.Fatal().Err(err).Msg("Could not resolve the packages to parse")
+		return
+	}
+
+	// Regenerate the variables files on change
+	if o.Watch {
+		if o.Check || o.DryRun || o.Diff {
+			log.Fatal().Msg("The --watch flag cannot be used with --check, --dry-run or --diff")
+			return
+		}
+		o.watch(dirs)
 		return
 	}
 
-	// Info message
-	log.Info().Msgf("Parsing files in %s", o.Dir)
+	// Each directory is processed with its own configuration
+	differences := 0
+	err = forEachScope(dirs, func(dir fs.Target) {
+		// Check the parser backend
+		if config.C.Backend != parser.BackendAST && config.C.Backend != parser.BackendPackages {
+			log.Fatal().Msgf("Unknown parser backend %s", config.C.Backend)
+			return
+		}
+
+		// Create the parser
+		p := parser.NewParser()
+
+		// Create the generator
+		g := generator.NewGenerator()
 
-	// Create the parser
-	p := parser.NewParser()
+		// Only generate the packages that changed since the previous generation
+		// The embedded structs of the other packages are not tracked, so the cache is not used with --flatten
+		if config.C.Cache && !config.C.Flatten && !o.Check && !o.DryRun && !o.Diff {
+			o.generateIncremental(p, g, dir)
+			return
+		}
 
-	// Create the generator
-	g := generator.NewGenerator()
+		// Parse the directory
+		scope := []fs.Target{dir}
+		parsedFiles, err := parseTargets(p, scope)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not list files project files to parse")
+			return
+		}
 
-	// Parse the working directory
-	parsedFiles, err := p.ParseDir(o.Dir, o.IsRecursive)
+		// Check the variables files
+		if o.Check {
+			differences += o.check(g, scope, parsedFiles)
+			return
+		}
+
+		// Print the variables files
+		if o.DryRun || o.Diff {
+			o.preview(cmd.OutOrStdout(), g, parsedFiles)
+			return
+		}
+
+		// Generate the variables files
+		o.generate(g, scope, parsedFiles)
+	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not list files project files to parse")
+		log.Fatal().Err(err).Msg("Could not load the configuration")
 		return
 	}
 
+	// Report the result of the check
+	// The level of the logger may have been changed by the configuration of the directories
+	if o.Check {
+		setLogLevel()
+		if differences > 0 {
+			log.Error().Msgf("%d variables files are not up to date", differences)
+			os.Exit(1)
+		}
+		log.Info().Msg("The variables files are up to date")
+	}
+}
+
+// generate writes the variables files and removes the variables files whose source
+// does not yield any struct anymore
+func (o *genOptions) generate(g *generator.Generator, dirs []fs.Target, parsedFiles map[parser.FilePath]*parser.File) {
 	// Generate the variables files
-	err = g.Generate(parsedFiles)
+	err := g.Generate(parsedFiles)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not generate variables files")
+		return
+	}
+
+	// Remove the variables files whose source does not yield any struct anymore
+	existing, err := generatedFiles(dirs)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not list generated files")
+		return
+	}
+	o.removeOrphans(g, parsedFiles, existing)
+}
+
+// removeOrphans removes the variables files whose source does not yield any struct anymore
+// With --keep-orphans, they are only reported
+func (o *genOptions) removeOrphans(g *generator.Generator, parsedFiles map[parser.FilePath]*parser.File, existing []string) {
+	orphans, err := g.Orphans(parsedFiles, existing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not find orphaned variables files")
+		return
+	}
+	if o.KeepOrphans {
+		for _, orphan := range orphans {
+			log.Warn().Msgf("Orphaned variables file %s", orphan)
+		}
+		return
+	}
+	err = g.RemoveOrphans(orphans)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not delete orphaned variables files")
+		return
+	}
+}
+
+// check compares the generated code with the variables files on disk
+// It logs and returns the number of files that are stale, missing or orphaned
+func (o *genOptions) check(g *generator.Generator, dirs []fs.Target, parsedFiles map[parser.FilePath]*parser.File) int {
+	// List the variables files on disk
+	existing, err := generatedFiles(dirs)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not list generated files to check")
+		return 0
+	}
+
+	differences, err := g.Check(parsedFiles, existing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not check variables files")
+		return 0
+	}
+	for _, d := range differences {
+		log.Error().Msgf("%s: %s", d.Status, d.Path)
+	}
+	return len(differences)
+}
+
+// preview prints the variables files that would be written
+// With --diff, only the changes against the files on disk are printed
+func (o *genOptions) preview(out io.Writer, g *generator.Generator, parsedFiles map[parser.FilePath]*parser.File) {
+	outputs, err := g.Render(parsedFiles)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not generate variables files")
 		return
 	}
+
+	for _, output := range outputs {
+		if o.Diff {
+			diff, err := g.Diff(output)
+			if err != nil {
+				log.Fatal().Err(err).Msgf("Could not compare %s", output.Path)
+				return
+			}
+			_, _ = io.WriteString(out, diff)
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "// %s\n%s\n", output.Path, output.Code)
+	}
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/rs/zerolog/log"
+	"path/filepath"
+)
+
+// targets returns the directories to process
+// The Go package patterns given as arguments have the priority over the --dir and --recursive flags
+func targets(args []string, dir string, recursive bool) ([]fs.Target, error) {
+	if len(args) > 0 {
+		return fs.ResolvePatterns(args)
+	}
+
+	// Get the working directory
+	dir, err := fs.WorkDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return []fs.Target{{Dir: dir, Recursive: recursive}}, nil
+}
+
+// parseTargets parses the files of the directories
+func parseTargets(p *parser.Parser, dirs []fs.Target) (map[parser.FilePath]*parser.File, error) {
+	parsedFiles := make(map[parser.FilePath]*parser.File)
+	for _, dir := range dirs {
+		// Info message
+		log.Info().Msgf("Parsing files in %s", dir.Dir)
+
+		dirFiles, err := p.ParseDir(dir.Dir, dir.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		for path, file := range dirFiles {
+			parsedFiles[path] = file
+		}
+	}
+	return parsedFiles, nil
+}
+
+// generatedFiles lists the files matching the prefix and the suffix of the generated files
+// The directories selected by several patterns are listed once
+// The output directory of a non-recursive directory is listed too
+func generatedFiles(dirs []fs.Target) ([]string, error) {
+	files := make([]string, 0)
+	listed := make(map[string]bool)
+	for _, dir := range dirs {
+		dirFiles, err := fs.ListFiles(dir.Dir, dir.Recursive, fs.IsGeneratedFile)
+		if err != nil {
+			return nil, err
+		}
+		if outDir := filepath.Join(dir.Dir, config.C.OutDir); !dir.Recursive && outDir != dir.Dir {
+			if isDir, err := fs.IsDir(outDir); err == nil && isDir {
+				outFiles, err := fs.ListFiles(outDir, false, fs.IsGeneratedFile)
+				if err != nil {
+					return nil, err
+				}
+				dirFiles = append(dirFiles, outFiles...)
+			}
+		}
+		for _, file := range dirFiles {
+			if !listed[file] {
+				listed[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/go-mods/tagsvar/modules/generator"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/go-mods/tagsvar/modules/watcher"
+	"github.com/rs/zerolog/log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// watchSession holds the parsed files of the watched directories
+// The parsed files are kept between the changes, so that only the changed files are parsed again
+type watchSession struct {
+	o    *genOptions
+	dirs []fs.Target
+	// parsed are the parsed files by directory
+	parsed map[fs.Target]map[parser.FilePath]*parser.File
+}
+
+// watch regenerates the variables files when the source files change
+// It runs until the process is interrupted
+func (o *genOptions) watch(dirs []fs.Target) {
+	s := &watchSession{o: o, dirs: dirs, parsed: make(map[fs.Target]map[parser.FilePath]*parser.File)}
+
+	// Generate all the variables files
+	err := forEachScope(dirs, s.generateAll)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not load the configuration")
+		return
+	}
+
+	w, err := watcher.NewWatcher(s.list, o.Interval, o.Debounce)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not list the files to watch")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Info().Msg("Watching for changes, press Ctrl+C to stop")
+	err = w.Watch(ctx, s.changed)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not watch the files")
+		return
+	}
+}
+
+// list lists the watched files: the Go files of the directories and the configuration files
+// applying to them, up to the module root
+func (s *watchSession) list() ([]string, error) {
+	files := make([]string, 0)
+	listed := make(map[string]bool)
+	err := forEachScope(s.dirs, func(dir fs.Target) {
+		dirFiles, err := fs.ListFiles(dir.Dir, dir.Recursive, fs.IsProjectFile)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not list the files of %s", dir.Dir)
+			return
+		}
+		configFiles, err := config.Files(dir.Dir)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not list the configuration files of %s", dir.Dir)
+			return
+		}
+		for _, file := range append(dirFiles, configFiles...) {
+			if !listed[file] {
+				listed[file] = true
+				files = append(files, file)
+			}
+		}
+	})
+	return files, err
+}
+
+// changed regenerates the variables files affected by the changes
+// A change of a configuration file regenerates all the variables files
+func (s *watchSession) changed(changes watcher.Changes) {
+	for _, file := range append(changes.Modified, changes.Removed...) {
+		if isConfigFile(file) {
+			log.Info().Msgf("Configuration changed, regenerating all the variables files")
+			s.parsed = make(map[fs.Target]map[parser.FilePath]*parser.File)
+			if err := forEachScope(s.dirs, s.generateAll); err != nil {
+				log.Error().Err(err).Msg("Could not load the configuration")
+			}
+			return
+		}
+	}
+
+	err := forEachScope(s.dirs, func(dir fs.Target) {
+		s.update(dir, changes)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not load the configuration")
+	}
+}
+
+// generateAll parses all the files of the directory and generates the variables files
+func (s *watchSession) generateAll(dir fs.Target) {
+	// Check the parser backend
+	if config.C.Backend != parser.BackendAST && config.C.Backend != parser.BackendPackages {
+		log.Error().Msgf("Unknown parser backend %s", config.C.Backend)
+		return
+	}
+
+	parsedFiles, err := parseTargets(parser.NewParser(), []fs.Target{dir})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not list files project files to parse")
+		return
+	}
+	s.parsed[dir] = parsedFiles
+	s.write(dir)
+}
+
+// update parses the changed files of the directory again and rewrites the affected variables files
+// With --flatten, or when the package defaults change, all the files of the changed packages are parsed again
+func (s *watchSession) update(dir fs.Target, changes watcher.Changes) {
+	parsedFiles, ok := s.parsed[dir]
+	if !ok {
+		// New directory with its own configuration
+		s.generateAll(dir)
+		return
+	}
+
+	// Files of the directory that are parsed with the current build constraints
+	files, err := fs.ListFiles(dir.Dir, dir.Recursive, fs.IsBuildFile)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not list the files of %s", dir.Dir)
+		return
+	}
+	buildFiles := make(map[string]bool, len(files))
+	for _, file := range files {
+		buildFiles[file] = true
+	}
+
+	// Files to parse again
+	reparse := make(map[string]bool)
+	affected := false
+	for _, file := range changes.Removed {
+		if _, ok := parsedFiles[parser.FilePath(file)]; ok {
+			delete(parsedFiles, parser.FilePath(file))
+			affected = true
+		}
+	}
+	for _, file := range changes.Modified {
+		if !buildFiles[file] {
+			// The file is excluded by the build constraints
+			if _, ok := parsedFiles[parser.FilePath(file)]; ok {
+				delete(parsedFiles, parser.FilePath(file))
+				affected = true
+			}
+			continue
+		}
+		reparse[file] = true
+		affected = true
+		if config.C.Flatten || filepath.Base(file) == "doc.go" {
+			for _, f := range files {
+				if filepath.Dir(f) == filepath.Dir(file) {
+					reparse[f] = true
+				}
+			}
+		}
+	}
+	if !affected {
+		return
+	}
+
+	// Parse the changed files
+	p := parser.NewParser()
+	for file := range reparse {
+		log.Debug().Msgf("Parsing file %s", file)
+		parsedFile, err := p.ParseFile(file)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not parse file %s", file)
+			return
+		}
+		parsedFiles[parser.FilePath(file)] = parsedFile
+	}
+
+	s.write(dir)
+}
+
+// write writes the variables files of the directory whose content changed, and removes the orphaned files
+// The errors are logged and do not stop the watch
+func (s *watchSession) write(dir fs.Target) {
+	parsedFiles := s.parsed[dir]
+	g := generator.NewGenerator()
+
+	outputs, err := g.Render(parsedFiles)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not generate variables files")
+		return
+	}
+	if err = g.Write(outputs); err != nil {
+		log.Error().Err(err).Msg("Could not write variables files")
+		return
+	}
+
+	existing, err := generatedFiles([]fs.Target{dir})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not list generated files")
+		return
+	}
+	orphans, err := g.Orphans(parsedFiles, existing)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not find orphaned variables files")
+		return
+	}
+	if s.o.KeepOrphans {
+		for _, orphan := range orphans {
+			log.Warn().Msgf("Orphaned variables file %s", orphan)
+		}
+		return
+	}
+	if err = g.RemoveOrphans(orphans); err != nil {
+		log.Error().Err(err).Msg("Could not delete orphaned variables files")
+	}
+}
+
+// isConfigFile checks if the file is a configuration file
+func isConfigFile(file string) bool {
+	for _, name := range config.FileNames {
+		if filepath.Base(file) == name {
+			return true
+		}
+	}
+	return false
+}
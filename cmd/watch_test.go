@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/go-mods/tagsvar/modules/watcher"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWatchSession_list(t *testing.T) {
+	t.Setenv("TAGSVAR_SILENT", "true")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                       "module example.com/module\n",
+		".tagsvar.yaml":                "suffix: .vars\n",
+		"models/.tagsvar.yaml":         "mode: flat\n",
+		"models/user.go":               "package models\n",
+		"models/entities/.tagsvar.yml": "mode: namespace\n",
+		"models/entities/post.go":      "package entities\n",
+	})
+
+	s := &watchSession{o: &genOptions{}, dirs: []fs.Target{{Dir: filepath.Join(dir, "models"), Recursive: true}}}
+	files, err := s.list()
+	if err != nil {
+		t.Fatalf("list() error = %v", err)
+	}
+
+	want := []string{
+		filepath.Join(dir, ".tagsvar.yaml"),
+		filepath.Join(dir, "models", ".tagsvar.yaml"),
+		filepath.Join(dir, "models", "user.go"),
+		filepath.Join(dir, "models", "entities", ".tagsvar.yml"),
+		filepath.Join(dir, "models", "entities", "post.go"),
+	}
+	slices.Sort(files)
+	slices.Sort(want)
+	if !slices.Equal(files, want) {
+		t.Errorf("list() = %v, want %v", files, want)
+	}
+}
+
+func TestWatchSession_update(t *testing.T) {
+	t.Setenv("TAGSVAR_SILENT", "true")
+
+	dir := t.TempDir()
+	models := filepath.Join(dir, "models")
+	source := func(name string, fields string) string {
+		return "package models\n\n// #tagsvar\ntype " + name + " struct {\n" + fields + "}\n"
+	}
+	writeFiles(t, dir, map[string]string{
+		"go.mod":            "module example.com/module\n",
+		"models/user.go":    source("User", "\tID int `json:\"id\" xml:\"id\"`\n"),
+		"models/post.go":    source("Post", "\tID int `json:\"id\" xml:\"id\"`\n"),
+		"models/comment.go": source("Comment", "\tID int `json:\"id\" xml:\"id\"`\n"),
+	})
+
+	target := fs.Target{Dir: models}
+	s := &watchSession{o: &genOptions{}, dirs: []fs.Target{target}, parsed: make(map[fs.Target]map[parser.FilePath]*parser.File)}
+	if err := forEachScope(s.dirs, s.generateAll); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		change   func() watcher.Changes
+		parsed   []string
+		contains map[string]string
+		removed  []string
+	}{
+		{
+			name: "modified",
+			change: func() watcher.Changes {
+				writeFiles(t, dir, map[string]string{"models/user.go": source("User", "\tID int `json:\"id\" xml:\"id\"`\n\tName string `json:\"name\" xml:\"name\"`\n")})
+				return watcher.Changes{Modified: []string{filepath.Join(models, "user.go")}}
+			},
+			parsed:   []string{"comment.go", "post.go", "user.go"},
+			contains: map[string]string{"user.vars.go": "JsonUserName"},
+		},
+		{
+			name: "removed",
+			change: func() watcher.Changes {
+				if err := os.Remove(filepath.Join(models, "comment.go")); err != nil {
+					t.Fatal(err)
+				}
+				return watcher.Changes{Removed: []string{filepath.Join(models, "comment.go")}}
+			},
+			parsed:  []string{"post.go", "user.go"},
+			removed: []string{"comment.vars.go"},
+		},
+		{
+			name: "excluded by the build constraints",
+			change: func() watcher.Changes {
+				writeFiles(t, dir, map[string]string{"models/post.go": "//go:build ignore\n\n" + source("Post", "\tID int `json:\"id\"`\n")})
+				return watcher.Changes{Modified: []string{filepath.Join(models, "post.go")}}
+			},
+			parsed: []string{"user.go"},
+			// As with gen, the variables file of a source excluded by the build constraints is not an orphan
+			contains: map[string]string{"post.vars.go": "JsonPostId"},
+		},
+		{
+			name: "package directive",
+			change: func() watcher.Changes {
+				writeFiles(t, dir, map[string]string{"models/doc.go": "// #tagsvar:mode=namespace\npackage models\n"})
+				return watcher.Changes{Modified: []string{filepath.Join(models, "doc.go")}}
+			},
+			parsed: []string{"doc.go", "user.go"},
+			// The package options apply to the files which were not changed
+			contains: map[string]string{"user.vars.go": "UserJson = struct"},
+			removed:  []string{"doc.vars.go"},
+		},
+	}
+
+	for _, test := range tests {
+		s.update(target, test.change())
+
+		var parsed []string
+		for path := range s.parsed[target] {
+			parsed = append(parsed, filepath.Base(string(path)))
+		}
+		slices.Sort(parsed)
+		if !slices.Equal(parsed, test.parsed) {
+			t.Errorf("%s: update() parsed = %v, want %v", test.name, parsed, test.parsed)
+		}
+
+		for name, want := range test.contains {
+			content, err := os.ReadFile(filepath.Join(models, name))
+			if err != nil || !strings.Contains(string(content), want) {
+				t.Errorf("%s: update() %s should contain %s, error = %v:\n%s", test.name, name, want, err, content)
+			}
+		}
+		for _, name := range test.removed {
+			if _, err := os.Stat(filepath.Join(models, name)); !os.IsNotExist(err) {
+				t.Errorf("%s: update() %s should not exist, error = %v", test.name, name, err)
+			}
+		}
+	}
+
+}
+
+// writeFiles writes the files in the directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/go-mods/tagsvar
 
-go 1.21.0
+go 1.22.0
 
 require (
 	github.com/go-mods/tags v1.1.3
@@ -8,9 +8,14 @@ require (
 	github.com/golobby/cast v1.3.3
 	github.com/golobby/config/v3 v3.4.2
 	github.com/mattn/go-isatty v0.0.20
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/rs/zerolog v1.31.0
 	github.com/spf13/cobra v1.8.0
+	github.com/spf13/pflag v1.0.5
 	github.com/stoewer/go-strcase v1.3.0
+	golang.org/x/mod v0.21.0
+	golang.org/x/tools v0.26.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -20,8 +25,7 @@ require (
 	github.com/gookit/color v1.5.4 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/sys v0.14.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
 )
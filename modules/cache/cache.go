@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/go-mods/tagsvar/modules/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Entry is the state of a package directory after a generation
+type Entry struct {
+	// Key is the hash of the tool version, the configuration and the source files of the directory
+	Key string `json:"key"`
+	// Outputs are the hashes of the variables files generated for the directory, by path
+	Outputs map[string]string `json:"outputs"`
+}
+
+// Cache stores the state of the package directories after a generation
+// A directory whose key did not change, and whose variables files were not modified, can be skipped
+//
+// There is one entry file per directory, named after the hash of the directory path
+type Cache struct {
+	dir string
+}
+
+// NewCache creates a cache stored in the directory
+// The tagsvar directory of the user cache directory is used if the directory is empty
+// ($XDG_CACHE_HOME/tagsvar on Linux)
+func NewCache(dir string) (*Cache, error) {
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userDir, "tagsvar")
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Key returns the key of a package directory from its source files
+// It hashes the tool version, the effective configuration, the template file,
+// and the paths and the contents of the source files
+func Key(files []string) (string, error) {
+	h := sha256.New()
+
+	// Tool version and configuration
+	// The output level does not change the generated code
+	c := *config.C
+	c.Verbose, c.Silent = false, false
+	if err := json.NewEncoder(h).Encode(c); err != nil {
+		return "", err
+	}
+	if c.Template != "" {
+		if err := hashFile(h, c.Template); err != nil {
+			return "", err
+		}
+	}
+
+	// Source files, by path
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	for _, file := range sorted {
+		_, _ = io.WriteString(h, file+"\n")
+		if err := hashFile(h, file); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Hash returns the hash of a content
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile writes the content of the file to the hash
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Lookup checks if the package directory is up to date
+// The key must match the stored key, and the variables files must not have been modified or deleted
+func (c *Cache) Lookup(dir string, key string) (bool, error) {
+	entry, err := c.load(dir)
+	if err != nil || entry == nil || entry.Key != key {
+		return false, err
+	}
+
+	for path, hash := range entry.Outputs {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if Hash(content) != hash {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Store records the state of the package directory
+// outputs are the contents of the variables files generated for the directory, by path
+// The entry file is only written if it changed
+func (c *Cache) Store(dir string, key string, outputs map[string][]byte) error {
+	entry := Entry{Key: key, Outputs: make(map[string]string, len(outputs))}
+	for path, content := range outputs {
+		entry.Outputs[path] = Hash(content)
+	}
+
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := c.path(dir)
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		return nil
+	}
+	if err = os.MkdirAll(c.dir, 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+// load reads the entry of the package directory
+// It returns nil if the directory has no entry, or if the entry cannot be decoded
+func (c *Cache) load(dir string) (*Entry, error) {
+	content, err := os.ReadFile(c.path(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+	if err = json.Unmarshal(content, entry); err != nil {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// path returns the path of the entry file of the package directory
+func (c *Cache) path(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return filepath.Join(c.dir, Hash([]byte(dir))[:32]+".json")
+}
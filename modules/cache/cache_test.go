@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.go")
+	if err := os.WriteFile(user, []byte("package models\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(c config.AppConfig) { *config.C = c }(*config.C)
+
+	key, err := Key([]string{user})
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+
+	// The output level does not change the key
+	config.C.Verbose = true
+	if got, _ := Key([]string{user}); got != key {
+		t.Errorf("Key() changed with the output level")
+	}
+
+	// The configuration changes the key
+	config.C.Mode = "both"
+	if got, _ := Key([]string{user}); got == key {
+		t.Errorf("Key() did not change with the configuration")
+	}
+	config.C.Mode = "flat"
+
+	// The content of the sources changes the key
+	if err := os.WriteFile(user, []byte("package models\n\ntype User struct{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Key([]string{user}); got == key {
+		t.Errorf("Key() did not change with the content of the sources")
+	}
+}
+
+func TestCache_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "user.vars.go")
+	code := []byte("package models\n")
+	if err := os.WriteFile(output, code, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func(key string, want bool) {
+		t.Helper()
+		got, err := c.Lookup(dir, key)
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("Lookup(%s) = %v, want %v", key, got, want)
+		}
+	}
+
+	// No entry
+	lookup("key", false)
+
+	if err := c.Store(dir, "key", map[string][]byte{output: code}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	lookup("key", true)
+	lookup("other", false)
+
+	// The entry is not written again if it did not change
+	entry := c.path(dir)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(entry, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(dir, "key", map[string][]byte{output: code}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if info, err := os.Stat(entry); err != nil || !info.ModTime().Equal(past) {
+		t.Errorf("Store() should not write an unchanged entry")
+	}
+
+	// Modified variables file
+	if err := os.WriteFile(output, []byte("package edited\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lookup("key", false)
+
+	// Deleted variables file
+	if err := os.Remove(output); err != nil {
+		t.Fatal(err)
+	}
+	lookup("key", false)
+}
@@ -6,6 +6,8 @@ import (
 	configLoader "github.com/golobby/config/v3"
 	"github.com/golobby/config/v3/pkg/feeder"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+	"path/filepath"
 )
 
 // C is the global config
@@ -20,21 +22,73 @@ var Version = "development"
 // It is set at build time
 var BuildDate = "not defined"
 
+// flags are the command line flags overriding the configuration
+var flags *pflag.FlagSet
+
 // AppConfig holds the configuration of the application
-// It is loaded from environment variables or used default values.
+// It is loaded from the flags, the environment variables, the configuration files
+// or the default values, by order of precedence.
 type AppConfig struct {
 	// Version is the version of the application
-	Version string
+	Version string `yaml:"-" toml:"-"`
 	// BuildDate is the date of the build
-	BuildDate string
+	BuildDate string `yaml:"-" toml:"-"`
 	// Prefix is the prefix of the generated files
-	Prefix string `env:"TAGSVAR_PREFIX" default:""`
+	Prefix string `env:"TAGSVAR_PREFIX" default:"" yaml:"prefix" toml:"prefix"`
 	// Suffix is the suffix of the generated files
-	Suffix string `env:"TAGSVAR_SUFFIx" default:".vars"`
+	Suffix string `env:"TAGSVAR_SUFFIX" default:".vars" yaml:"suffix" toml:"suffix"`
+	// Template is the path of a template file redefining the templates of the generated code
+	Template string `env:"TAGSVAR_TEMPLATE" default:"" yaml:"template" toml:"template" flag:"template"`
+	// Naming is the template of the generated identifiers
+	Naming string `env:"TAGSVAR_NAMING" default:"{{.Tag}}{{.Struct}}{{.Field}}" yaml:"naming" toml:"naming" flag:"naming"`
+	// Mode is the emission mode of the identifiers (flat, namespace or both)
+	Mode string `env:"TAGSVAR_MODE" default:"flat" yaml:"mode" toml:"mode" flag:"mode"`
+	// Helpers generates the lists, the maps and the lookup functions of the tag names
+	Helpers bool `env:"TAGSVAR_HELPERS" default:"false" yaml:"helpers" toml:"helpers" flag:"helpers"`
+	// OptionsMode is the emission mode of the tag options (map, typed or struct)
+	OptionsMode string `env:"TAGSVAR_OPTIONS" default:"map" yaml:"options" toml:"options" flag:"options"`
+	// OptionsSchema types the tag options (comma separated tag.option=type, ie: gorm.size=int)
+	OptionsSchema string `env:"TAGSVAR_OPTIONS_SCHEMA" default:"" yaml:"options_schema" toml:"options_schema" flag:"options-schema"`
+	// Initialisms writes the Go initialisms in upper case in the generated identifiers (ie: ID, JSON)
+	Initialisms bool `env:"TAGSVAR_INITIALISMS" default:"false" yaml:"initialisms" toml:"initialisms" flag:"initialisms"`
+	// Unexported generates unexported identifiers
+	Unexported bool `env:"TAGSVAR_UNEXPORTED" default:"false" yaml:"unexported" toml:"unexported" flag:"unexported"`
+	// Disambiguate renames the colliding identifiers with a numeric suffix instead of failing
+	Disambiguate bool `env:"TAGSVAR_DISAMBIGUATE" default:"false" yaml:"disambiguate" toml:"disambiguate" flag:"disambiguate"`
+	// PerPackage generates a single variables file per package
+	PerPackage bool `env:"TAGSVAR_PER_PACKAGE" default:"false" yaml:"per_package" toml:"per_package" flag:"per-package"`
+	// PackageFile is the name of the variables file of a package
+	PackageFile string `env:"TAGSVAR_PACKAGE_FILE" default:"tagsvar_gen.go" yaml:"package_file" toml:"package_file" flag:"package-file"`
+	// OutDir is the directory of the variables files, relative to the directory of the source package
+	OutDir string `env:"TAGSVAR_OUT_DIR" default:"" yaml:"out_dir" toml:"out_dir" flag:"out-dir"`
+	// OutPackage is the package name of the variables files generated in OutDir (defaults to the directory name)
+	OutPackage string `env:"TAGSVAR_OUT_PACKAGE" default:"" yaml:"out_package" toml:"out_package" flag:"out-package"`
+	// Cache skips the package directories that did not change since the previous generation
+	Cache bool `env:"TAGSVAR_CACHE" default:"true" yaml:"cache" toml:"cache" flag:"cache"`
+	// CacheDir is the directory of the cache (defaults to the tagsvar directory of the user cache directory)
+	CacheDir string `env:"TAGSVAR_CACHE_DIR" default:"" yaml:"cache_dir" toml:"cache_dir" flag:"cache-dir"`
+	// Jobs is the number of files parsed and written concurrently (defaults to GOMAXPROCS)
+	Jobs int `env:"TAGSVAR_JOBS" default:"0" yaml:"jobs" toml:"jobs" flag:"jobs"`
+	// Include is the list of globs of the files to process (comma separated)
+	Include string `env:"TAGSVAR_INCLUDE" default:"" yaml:"include" toml:"include" flag:"include"`
+	// Exclude is the list of globs of the files and directories to skip (comma separated)
+	Exclude string `env:"TAGSVAR_EXCLUDE" default:"" yaml:"exclude" toml:"exclude" flag:"exclude"`
+	// Tags is the list of build tags used to select the files (comma separated)
+	Tags string `env:"TAGSVAR_TAGS" default:"" yaml:"tags" toml:"tags" flag:"tags"`
+	// GOOS is the target operating system used to select the files (defaults to the go environment)
+	GOOS string `env:"TAGSVAR_GOOS" default:"" yaml:"goos" toml:"goos" flag:"goos"`
+	// GOARCH is the target architecture used to select the files (defaults to the go environment)
+	GOARCH string `env:"TAGSVAR_GOARCH" default:"" yaml:"goarch" toml:"goarch" flag:"goarch"`
+	// Backend is the parser backend (ast or packages)
+	Backend string `env:"TAGSVAR_BACKEND" default:"ast" yaml:"backend" toml:"backend" flag:"backend"`
+	// Flatten flattens the embedded structs into the embedding struct
+	Flatten bool `env:"TAGSVAR_FLATTEN" default:"false" yaml:"flatten" toml:"flatten" flag:"flatten"`
+	// Dialects applies the semantics of the well-known tag keys (json, gorm, ...)
+	Dialects bool `env:"TAGSVAR_DIALECTS" default:"false" yaml:"dialects" toml:"dialects" flag:"dialects"`
 	// Verbose enables verbose output
-	Verbose bool `env:"TAGSVAR_VERBOSE" default:"false"`
+	Verbose bool `env:"TAGSVAR_VERBOSE" default:"false" yaml:"verbose" toml:"verbose" flag:"verbose"`
 	// Silent disables output
-	Silent bool `env:"TAGSVAR_SILENT" default:"false"`
+	Silent bool `env:"TAGSVAR_SILENT" default:"false" yaml:"silent" toml:"silent" flag:"silent"`
 }
 
 // init initializes the config
@@ -43,11 +97,32 @@ func init() {
 	C = &AppConfig{}
 
 	// Load configuration access
-	C.load()
+	if err := C.load(""); err != nil {
+		log.Fatal().Err(err).Msg("Could not load environment variables")
+	}
 }
 
-// load loads the configuration from default values and environment variables
-func (c *AppConfig) load() {
+// BindFlags sets the command line flags overriding the configuration
+// Only the flags set on the command line are used, see Load
+func BindFlags(flagSet *pflag.FlagSet) {
+	flags = flagSet
+}
+
+// Load reloads the configuration for a directory
+// The configuration files from the module root down to the directory are loaded, see Files
+func Load(dir string) error {
+	c := &AppConfig{}
+	if err := c.load(dir); err != nil {
+		return err
+	}
+	*C = *c
+	return nil
+}
+
+// load loads the configuration from default values, configuration files of the directory,
+// environment variables and flags
+// No configuration file is loaded if the directory is empty
+func (c *AppConfig) load(dir string) error {
 
 	// Create the config loader
 	loader := configLoader.New()
@@ -55,14 +130,29 @@ func (c *AppConfig) load() {
 	// Add feeder to load from default values
 	loader.AddFeeder(myfeeder.Default{})
 
+	// Add feeders to load from the configuration files
+	if dir != "" {
+		files, err := Files(dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if filepath.Ext(file) == ".toml" {
+				loader.AddFeeder(feeder.Toml{Path: file})
+			} else {
+				loader.AddFeeder(myfeeder.Yaml{Path: file})
+			}
+		}
+	}
+
 	// Add feeder to load from environment variables
 	loader.AddFeeder(feeder.Env{})
 
+	// Add feeder to load from the command line flags
+	loader.AddFeeder(myfeeder.Flags{FlagSet: flags})
+
 	// Read config access
-	err := loader.AddStruct(c).Feed()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Could not load environment variables")
-	}
+	return loader.AddStruct(c).Feed()
 }
 
 // Setup : this function is called while the config is loaded by golobby/config
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles writes the files in the directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".tagsvar.yaml":               "",
+		"module/go.mod":               "module example.com/module\n",
+		"module/.tagsvar.toml":        "",
+		"module/models/.tagsvar.yml":  "",
+		"module/models/.tagsvar.toml": "",
+		"module/models/user/user.go":  "",
+	})
+
+	files, err := Files(filepath.Join(dir, "module", "models", "user"))
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "module", ".tagsvar.toml"),
+		filepath.Join(dir, "module", "models", ".tagsvar.yml"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Files() = %v, want %v", files, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	saved := *C
+	defer func() {
+		*C = saved
+		flags = nil
+	}()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":               "module example.com/module\n",
+		".tagsvar.yaml":        "suffix: .gen\nmode: both\nhelpers: true\nnaming: \"{{.Struct}}{{.Field}}\"\n",
+		"models/.tagsvar.toml": "mode = \"namespace\"\nper_package = true\n",
+	})
+
+	flagSet := pflag.NewFlagSet("gen", pflag.ContinueOnError)
+	flagSet.String("naming", "", "")
+	flagSet.Bool("helpers", false, "")
+	flagSet.Bool("flatten", false, "")
+	if err := flagSet.Parse([]string{"--helpers=false"}); err != nil {
+		t.Fatal(err)
+	}
+	BindFlags(flagSet)
+	t.Setenv("TAGSVAR_NAMING", "{{.Field}}")
+
+	var tests = []struct {
+		name string
+		dir  string
+		want AppConfig
+	}{
+		{
+			name: "module root",
+			dir:  dir,
+			want: AppConfig{Suffix: ".gen", Mode: "both", Helpers: false, Naming: "{{.Field}}", PerPackage: false},
+		},
+		{
+			name: "nested configuration",
+			dir:  filepath.Join(dir, "models"),
+			want: AppConfig{Suffix: ".gen", Mode: "namespace", Helpers: false, Naming: "{{.Field}}", PerPackage: true},
+		},
+	}
+	for _, test := range tests {
+		if err := Load(test.dir); err != nil {
+			t.Errorf("%s: Load() error = %v", test.name, err)
+			continue
+		}
+		got := AppConfig{Suffix: C.Suffix, Mode: C.Mode, Helpers: C.Helpers, Naming: C.Naming, PerPackage: C.PerPackage}
+		if got != test.want {
+			t.Errorf("%s: Load() = %+v, want %+v", test.name, got, test.want)
+		}
+		// Default values
+		if C.OptionsMode != "map" || C.PackageFile != "tagsvar_gen.go" || C.Dialects {
+			t.Errorf("%s: Load() should keep the default values: %+v", test.name, C)
+		}
+	}
+}
+
+func TestLoadEmptyFiles(t *testing.T) {
+	saved := *C
+	defer func() { *C = saved }()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                 "module example.com/module\n",
+		".tagsvar.yaml":          "",
+		"models/.tagsvar.yml":    "# no settings\n",
+		"services/.tagsvar.toml": "",
+	})
+
+	for _, sub := range []string{"", "models", "services"} {
+		if err := Load(filepath.Join(dir, sub)); err != nil {
+			t.Errorf("Load(%s) error = %v", sub, err)
+			continue
+		}
+		if C.Suffix != ".vars" || C.OptionsMode != "map" {
+			t.Errorf("Load(%s) should keep the default values: %+v", sub, C)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package feeder
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golobby/cast"
+	"github.com/spf13/pflag"
+	"reflect"
+	"unsafe"
+)
+
+// Flags feeder.
+// It feeds the fields using the flags set on the command line if flag tag exists.
+// The flags that are not set on the command line are ignored.
+//
+// Example:
+//
+//	type Config struct {
+//		Mode string `flag:"mode"`
+//	}
+type Flags struct {
+	FlagSet *pflag.FlagSet
+}
+
+// Feed feeds the structure with the flags set on the command line.
+func (f Flags) Feed(structure interface{}) error {
+	inputType := reflect.TypeOf(structure)
+	if inputType != nil {
+		if inputType.Kind() == reflect.Ptr {
+			if inputType.Elem().Kind() == reflect.Struct {
+				return f.fillStruct(reflect.ValueOf(structure).Elem())
+			}
+		}
+	}
+
+	return errors.New("flags: invalid structure")
+}
+
+// fillStruct sets a reflected struct fields with the value of the flags.
+func (f Flags) fillStruct(s reflect.Value) error {
+	if f.FlagSet == nil {
+		return nil
+	}
+	for i := 0; i < s.NumField(); i++ {
+		name, exist := s.Type().Field(i).Tag.Lookup("flag")
+		if !exist {
+			continue
+		}
+		flag := f.FlagSet.Lookup(name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		v, err := cast.FromType(flag.Value.String(), s.Type().Field(i).Type)
+		if err != nil {
+			return fmt.Errorf("flags: cannot set `%v` field; err: %v", s.Type().Field(i).Name, err)
+		}
+		// #nosec G103
+		ptr := reflect.NewAt(s.Field(i).Type(), unsafe.Pointer(s.Field(i).UnsafeAddr())).Elem()
+		ptr.Set(reflect.ValueOf(v))
+	}
+	return nil
+}
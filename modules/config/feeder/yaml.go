@@ -0,0 +1,31 @@
+package feeder
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Yaml feeder.
+// It feeds the fields using a YAML file, like the golobby Yaml feeder.
+// An empty file, or a file holding only comments, feeds nothing.
+type Yaml struct {
+	Path string
+}
+
+// Feed feeds the structure with the content of the YAML file.
+func (f Yaml) Feed(structure interface{}) error {
+	file, err := os.Open(filepath.Clean(f.Path))
+	if err != nil {
+		return fmt.Errorf("yaml: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if err = yaml.NewDecoder(file).Decode(structure); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("yaml: %s: %v", f.Path, err)
+	}
+	return nil
+}
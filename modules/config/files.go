@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// FileNames are the names of the configuration files, by order of priority
+// Only the first configuration file found in a directory is loaded
+var FileNames = []string{".tagsvar.yaml", ".tagsvar.yml", ".tagsvar.toml"}
+
+// FindFile returns the path of the configuration file of the directory
+// It returns an empty string if the directory has no configuration file
+func FindFile(dir string) string {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Files returns the configuration files applying to the directory
+// The files are searched from the directory up to the module root (the directory of the go.mod file),
+// or up to the root of the file system outside a module
+// They are returned from the module root down to the directory, the nested files overriding the parent ones
+func Files(dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for {
+		if file := FindFile(dir); file != "" {
+			files = append([]string{file}, files...)
+		}
+
+		// Stop at the module root
+		_, err := os.Stat(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			return files, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return files, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,41 @@
+package fs
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"go/build"
+	"path/filepath"
+	"strings"
+)
+
+// BuildContext returns the build context used to select the files
+// It is the default build context with the tags, GOOS and GOARCH of the configuration
+func BuildContext() build.Context {
+	ctxt := build.Default
+	if config.C.GOOS != "" {
+		ctxt.GOOS = config.C.GOOS
+	}
+	if config.C.GOARCH != "" {
+		ctxt.GOARCH = config.C.GOARCH
+	}
+	ctxt.BuildTags = BuildTags()
+	return ctxt
+}
+
+// BuildTags returns the build tags of the configuration
+// The tags are separated by commas or spaces, like the go command -tags flag
+func BuildTags() []string {
+	return strings.FieldsFunc(config.C.Tags, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// MatchBuildConstraints checks if the file satisfies the build constraints of the build context
+// Both the file name suffixes (_windows.go, _amd64.go) and the //go:build lines are evaluated
+func MatchBuildConstraints(path string) bool {
+	ctxt := BuildContext()
+	match, err := ctxt.MatchFile(filepath.Dir(path), filepath.Base(path))
+	return err == nil && match
+}
+
+// IsBuildFile checks if the file is a project file selected by the build constraints
+func IsBuildFile(path string) bool {
+	return IsProjectFile(path) && MatchBuildConstraints(path)
+}
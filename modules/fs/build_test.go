@@ -0,0 +1,53 @@
+package fs
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsBuildFile(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"user.go":         "package testdata\n",
+		"user_test.go":    "package testdata\n",
+		"user.vars.go":    "package testdata\n",
+		"user_windows.go": "package testdata\n",
+		"user_linux.go":   "package testdata\n",
+		"exclude.go":      "//go:build exclude\n\npackage testdata\n",
+		"user.txt":        "",
+	}
+	writeFiles(t, dir, files)
+
+	var tests = []struct {
+		tags, goos string
+		want       map[string]bool
+	}{
+		{
+			goos: "linux",
+			want: map[string]bool{"user.go": true, "user_linux.go": true},
+		},
+		{
+			goos: "windows",
+			want: map[string]bool{"user.go": true, "user_windows.go": true},
+		},
+		{
+			tags: "exclude", goos: "linux",
+			want: map[string]bool{"user.go": true, "user_linux.go": true, "exclude.go": true},
+		},
+	}
+
+	defer func(c config.AppConfig) { *config.C = c }(*config.C)
+
+	for _, test := range tests {
+		config.C.Tags = test.tags
+		config.C.GOOS = test.goos
+
+		for name := range files {
+			if got := IsBuildFile(filepath.Join(dir, name)); got != test.want[name] {
+				t.Errorf("IsBuildFile(%q) with tags %q and GOOS %q = %v, want %v", name, test.tags, test.goos, got, test.want[name])
+			}
+		}
+	}
+}
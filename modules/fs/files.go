@@ -3,43 +3,164 @@ package fs
 import (
 	"github.com/go-mods/tagsvar/modules/config"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
 // ListFiles lists files in a directory
-// The file name must be checked through the filter function
+// The file path must be checked through the filter function
 // If recursive is true, the files are listed in all subdirectories
+//
+// The vendor and testdata directories, the hidden directories and the paths ignored
+// by the .gitignore files are skipped, as well as the nested modules and the paths matching the exclude globs
+// of the configuration. When include globs are configured, only the matching files are listed
+//
+// The subdirectories holding their own configuration file are skipped, see ConfigDirs
 func ListFiles(dir string, recursive bool, filter func(string) bool) ([]string, error) {
 	files := make([]string, 0)
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	err := walk(dir, recursive, func(path string) {
+		// Skip files that do not match the filter function
+		if filter(path) {
+			files = append(files, path)
+		}
+	}, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// ConfigDirs lists the subdirectories of a directory holding their own configuration file
+// Their subtree is processed with their own configuration, so they are not listed by ListFiles
+// The subdirectories of a listed subdirectory are not searched
+func ConfigDirs(dir string) ([]string, error) {
+	dirs := make([]string, 0)
+
+	err := walk(dir, true, nil, func(path string) {
+		dirs = append(dirs, path)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+// walk walks the files of a directory with the rules of ListFiles
+// The visitFile function is called for the listed files, the visitConfigDir function for the
+// subdirectories holding their own configuration file
+func walk(dir string, recursive bool, visitFile func(string), visitConfigDir func(string)) error {
+	// Load the .gitignore files of the directory and its parents
+	ignore := newGitignore(dir)
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories if recursive is false
-		if info.IsDir() && !recursive {
-			return filepath.SkipDir
+		// The listed directory itself is never skipped
+		if path == dir {
+			return nil
 		}
 
-		// Get the file name
-		fileName := info.Name()
+		// Path relative to the listed directory, used to match the globs
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
 
-		// Skip files that do not match the filter function
-		if !filter(fileName) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			// Skip directories if recursive is false
+			if !recursive || IsSkippedDir(info.Name()) || ignore.ignored(absPath, true) || matchAny(Excludes(), rel) {
+				return filepath.SkipDir
+			}
+			// Skip the nested modules, like the go command does with ./...
+			if IsModuleDir(path) {
+				return filepath.SkipDir
+			}
+			// Skip directories with their own configuration
+			if config.FindFile(path) != "" {
+				if visitConfigDir != nil {
+					visitConfigDir(path)
+				}
+				return filepath.SkipDir
+			}
+			// Load the .gitignore file of the directory
+			ignore.load(absPath)
+			return nil
+		}
+
+		// Skip files that are ignored or excluded
+		if ignore.ignored(absPath, false) || matchAny(Excludes(), rel) {
+			return nil
+		}
+
+		// Skip files that are not included
+		if includes := Includes(); len(includes) > 0 && !matchAny(includes, rel) {
 			return nil
 		}
 
-		files = append(files, path)
+		if visitFile != nil {
+			visitFile(path)
+		}
 		return nil
 	})
+}
 
-	if err != nil {
-		return nil, err
+// IsSkippedDir checks if the directory is skipped while listing files
+// like the go command does for the vendor, testdata and hidden directories
+func IsSkippedDir(dirName string) bool {
+	return dirName == "vendor" || dirName == "testdata" || strings.HasPrefix(dirName, ".")
+}
+
+// Includes returns the include globs of the configuration
+func Includes() []string {
+	return splitList(config.C.Include)
+}
+
+// Excludes returns the exclude globs of the configuration
+func Excludes() []string {
+	return splitList(config.C.Exclude)
+}
+
+// splitList splits a comma separated list
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	return files, nil
+// matchAny checks if the slash separated relative path matches one of the globs
+// A glob without slash is matched against the name of the file or directory,
+// otherwise it is matched against the relative path (** matches any number of directories)
+func matchAny(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if !strings.Contains(glob, "/") {
+			if match, _ := path.Match(glob, path.Base(rel)); match {
+				return true
+			}
+			continue
+		}
+		if matchGlob(strings.TrimPrefix(glob, "./"), rel) {
+			return true
+		}
+	}
+	return false
 }
 
 // IsGoFile checks if the file is a .go file
@@ -49,7 +170,7 @@ func IsGoFile(fileName string) bool {
 
 // IsGeneratedFile checks if the file is a generated file
 // It must be a .go file
-// It must start with the prefix and end with the suffix
+// It must start with the prefix and end with the suffix, or be the package file
 func IsGeneratedFile(fileName string) bool {
 	// Get the file name only
 	fileName = filepath.Base(fileName)
@@ -57,6 +178,10 @@ func IsGeneratedFile(fileName string) bool {
 	if !IsGoFile(fileName) {
 		return false
 	}
+	// Check if the file is the package file
+	if IsPackageFile(fileName) {
+		return true
+	}
 	// Remove the extension
 	fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName))
 	// Check if the file starts with the prefix
@@ -70,6 +195,11 @@ func IsGeneratedFile(fileName string) bool {
 	return true
 }
 
+// IsPackageFile checks if the file is the variables file of a package
+func IsPackageFile(fileName string) bool {
+	return config.C.PackageFile != "" && filepath.Base(fileName) == config.C.PackageFile
+}
+
 // IsProjectFile checks if the file is a project file (not a generated file, neither a test file)
 func IsProjectFile(fileName string) bool {
 	// Get the file name only
@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files in the directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		".git/HEAD":           "",
+		".gitignore":          "/ignored/\n*.tmp.go\n!keep.tmp.go\n",
+		"user.go":             "",
+		"user.vars.go":        "",
+		"scratch.tmp.go":      "",
+		"keep.tmp.go":         "",
+		"models/blog.go":      "",
+		"models/.gitignore":   "draft.go\n",
+		"models/draft.go":     "",
+		"models/gen/x_gen.go": "",
+		"ignored/user.go":     "",
+		"vendor/lib/lib.go":   "",
+		"testdata/data.go":    "",
+		".hidden/hidden.go":   "",
+		"api/api.go":          "",
+		"api/api_test.go":     "",
+		"tools/go.mod":        "module example.com/tools\n",
+		"tools/tools.go":      "",
+	}
+	writeFiles(t, dir, files)
+
+	var tests = []struct {
+		name      string
+		dir       string
+		recursive bool
+		include   string
+		exclude   string
+		want      []string
+	}{
+		{
+			name: "non recursive",
+			dir:  dir,
+			want: []string{"keep.tmp.go", "user.go"},
+		},
+		{
+			name:      "recursive",
+			dir:       dir,
+			recursive: true,
+			want:      []string{"api/api.go", "keep.tmp.go", "models/blog.go", "models/gen/x_gen.go", "user.go"},
+		},
+		{
+			name:      "exclude",
+			dir:       dir,
+			recursive: true,
+			exclude:   "*_gen.go,api",
+			want:      []string{"keep.tmp.go", "models/blog.go", "user.go"},
+		},
+		{
+			name:      "include",
+			dir:       dir,
+			recursive: true,
+			include:   "models/**",
+			want:      []string{"models/blog.go", "models/gen/x_gen.go"},
+		},
+		{
+			name: "hidden root",
+			dir:  filepath.Join(dir, ".hidden"),
+			want: []string{"hidden.go"},
+		},
+	}
+
+	defer func(c config.AppConfig) { *config.C = c }(*config.C)
+
+	for _, test := range tests {
+		config.C.Include = test.include
+		config.C.Exclude = test.exclude
+
+		got, err := ListFiles(test.dir, test.recursive, IsProjectFile)
+		if err != nil {
+			t.Fatalf("%s: ListFiles() error = %v", test.name, err)
+		}
+
+		rel := make([]string, 0, len(got))
+		for _, path := range got {
+			r, _ := filepath.Rel(test.dir, path)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		sort.Strings(rel)
+
+		if strings.Join(rel, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: ListFiles() = %v, want %v", test.name, rel, test.want)
+		}
+	}
+}
+
+func TestConfigDirs(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		".tagsvar.yaml":              "",
+		"user.go":                    "",
+		"models/.tagsvar.yaml":       "",
+		"models/blog.go":             "",
+		"models/admin/.tagsvar.toml": "",
+		"models/admin/admin.go":      "",
+		"api/api.go":                 "",
+		"vendor/lib/.tagsvar.yaml":   "",
+	}
+	writeFiles(t, dir, files)
+
+	// The subdirectories with their own configuration are not listed
+	got, err := ListFiles(dir, true, IsProjectFile)
+	if err != nil {
+		t.Fatalf("ListFiles() error = %v", err)
+	}
+	want := []string{filepath.Join(dir, "api", "api.go"), filepath.Join(dir, "user.go")}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+
+	// Only the first level of the nested configurations is returned
+	dirs, err := ConfigDirs(dir)
+	if err != nil {
+		t.Fatalf("ConfigDirs() error = %v", err)
+	}
+	if want := []string{filepath.Join(dir, "models")}; strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("ConfigDirs() = %v, want %v", dirs, want)
+	}
+}
@@ -0,0 +1,155 @@
+package fs
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignore holds the rules of the .gitignore files
+// The rules are matched in order, the last matching rule wins
+type gitignore struct {
+	rules []gitignoreRule
+}
+
+// gitignoreRule is a line of a .gitignore file
+type gitignoreRule struct {
+	// base is the directory of the .gitignore file
+	base string
+	// pattern is the glob pattern, without the negation and the trailing slash
+	pattern string
+	// negate is true for the rules starting with !
+	negate bool
+	// dirOnly is true for the rules ending with /
+	dirOnly bool
+	// anchored is true for the rules containing a slash, which are relative to the base
+	anchored bool
+}
+
+// newGitignore loads the .gitignore files of the directory and of its parents,
+// up to the root of the git repository
+func newGitignore(dir string) *gitignore {
+	g := &gitignore{}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return g
+	}
+
+	// Collect the parents up to the repository root
+	dirs := []string{dir}
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			// Not in a git repository, only the directory is used
+			dirs = dirs[:1]
+			break
+		}
+		dirs = append(dirs, parent)
+		current = parent
+	}
+
+	// The rules of the parents come first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		g.load(dirs[i])
+	}
+	return g
+}
+
+// load adds the rules of the .gitignore file of the directory
+func (g *gitignore) load(dir string) {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := gitignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// ignored checks if the path is ignored by the rules
+func (g *gitignore) ignored(absPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, absPath)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		var match bool
+		if rule.anchored {
+			match = matchGlob(rule.pattern, rel)
+		} else {
+			match, _ = path.Match(rule.pattern, path.Base(rel))
+		}
+		if match {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlob checks if the slash separated path matches the glob pattern
+// The ** element matches any number of directories
+func matchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches the path elements against the pattern elements
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// ** matches zero or more elements
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if match, err := path.Match(pattern[0], name[0]); err != nil || !match {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Target is a directory selected by a package pattern
+type Target struct {
+	// Dir is the absolute path of the directory
+	Dir string
+	// Recursive is true if the subdirectories are selected too (pattern ending with /...)
+	Recursive bool
+}
+
+// ModuleRoot returns the root directory and the path of the module containing the directory
+// The go.mod file is searched from the directory up to the root of the file system
+func ModuleRoot(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			modulePath := modfile.ModulePath(content)
+			if modulePath == "" {
+				return "", "", fmt.Errorf("no module path in %s", filepath.Join(dir, "go.mod"))
+			}
+			return dir, modulePath, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("go.mod file not found in the current directory or any parent directory")
+		}
+		dir = parent
+	}
+}
+
+// ResolvePatterns resolves Go package patterns to directories
+//
+//	./models, ../api -> the directory, relative to the current directory
+//	./models/..., ./... -> the directory and all its subdirectories
+//	github.com/org/module/models -> the directory of the package, resolved against the module root
+//	github.com/org/module/... -> the directory of the package and all its subdirectories
+//
+// Like the go command, the patterns must select packages of the current module,
+// so that no variables file is written to the module cache or to the standard library
+func ResolvePatterns(patterns []string) ([]Target, error) {
+	targets := make([]Target, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		target, err := resolvePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// resolvePattern resolves a Go package pattern to a directory
+func resolvePattern(pattern string) (Target, error) {
+	target := Target{}
+
+	// The ... wildcard is only supported at the end of the pattern
+	path := filepath.ToSlash(pattern)
+	if path == "..." || strings.HasSuffix(path, "/...") {
+		target.Recursive = true
+		path = strings.TrimSuffix(strings.TrimSuffix(path, "..."), "/")
+	}
+	if strings.Contains(path, "...") {
+		return target, fmt.Errorf("unsupported pattern %s: the ... wildcard must end the pattern", pattern)
+	}
+
+	// Relative and absolute paths are resolved against the current directory
+	if path == "" || path == "." || path == ".." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || filepath.IsAbs(pattern) {
+		if path == "" {
+			path = "."
+		}
+		dir, err := WorkDir(filepath.FromSlash(path))
+		if err != nil {
+			return target, err
+		}
+		if err = checkMainModule(dir); err != nil {
+			return target, fmt.Errorf("cannot resolve pattern %s: %w", pattern, err)
+		}
+		target.Dir = dir
+		return target, nil
+	}
+
+	// Import paths are resolved against the module root
+	dir, err := importPathDir(path)
+	if err != nil {
+		return target, fmt.Errorf("cannot resolve pattern %s: %w", pattern, err)
+	}
+	target.Dir = dir
+	return target, nil
+}
+
+// importPathDir returns the directory of an import path of the current module
+func importPathDir(path string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	root, modulePath, err := ModuleRoot(cwd)
+	if err != nil {
+		return "", err
+	}
+	if path != modulePath && !strings.HasPrefix(path, modulePath+"/") {
+		return "", fmt.Errorf("package %s is outside the main module %s", path, modulePath)
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path, modulePath)))
+	if isDir, err := IsDir(dir); err != nil || !isDir {
+		return "", fmt.Errorf("directory %s not found", dir)
+	}
+	if err = checkMainModule(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// checkMainModule checks that the directory belongs to the module of the current directory
+// A directory of a nested module (with its own go.mod file) is outside the main module
+// Outside a module, every directory is accepted
+func checkMainModule(dir string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root, modulePath, err := ModuleRoot(cwd)
+	if err != nil {
+		return nil
+	}
+	if dirRoot, _, err := ModuleRoot(dir); err != nil || dirRoot != root {
+		return fmt.Errorf("directory %s is outside the main module %s", dir, modulePath)
+	}
+	return nil
+}
+
+// IsModuleDir checks if the directory is the root of a module (it holds a go.mod file)
+func IsModuleDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil && !info.IsDir()
+}
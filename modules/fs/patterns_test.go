@@ -0,0 +1,78 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePatterns(t *testing.T) {
+	// Resolve the symbolic links of the temporary directory to compare the paths
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"models", "models/user", "api", "tools"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "tools", "go.mod"), []byte("module example.com/app/tools\n\ngo 1.21\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The patterns are resolved from a subdirectory of the module
+	defer func(cwd string) { _ = os.Chdir(cwd) }(cwd)
+	if err := os.Chdir(filepath.Join(root, "models")); err != nil {
+		t.Fatal(err)
+	}
+
+	moduleRoot, modulePath, err := ModuleRoot(".")
+	if err != nil || moduleRoot != root || modulePath != "example.com/app" {
+		t.Errorf("ModuleRoot() = %q, %q, %v, want %q, %q", moduleRoot, modulePath, err, root, "example.com/app")
+	}
+
+	var tests = []struct {
+		pattern string
+		want    Target
+		wantErr bool
+	}{
+		{pattern: ".", want: Target{Dir: filepath.Join(root, "models")}},
+		{pattern: "./...", want: Target{Dir: filepath.Join(root, "models"), Recursive: true}},
+		{pattern: "./user", want: Target{Dir: filepath.Join(root, "models", "user")}},
+		{pattern: "../api", want: Target{Dir: filepath.Join(root, "api")}},
+		{pattern: "../...", want: Target{Dir: root, Recursive: true}},
+		{pattern: filepath.Join(root, "api"), want: Target{Dir: filepath.Join(root, "api")}},
+		{pattern: "example.com/app", want: Target{Dir: root}},
+		{pattern: "example.com/app/...", want: Target{Dir: root, Recursive: true}},
+		{pattern: "example.com/app/models/user", want: Target{Dir: filepath.Join(root, "models", "user")}},
+		{pattern: "example.com/app/missing", wantErr: true},
+		{pattern: "./.../user", wantErr: true},
+		{pattern: "fmt", wantErr: true},
+		{pattern: "github.com/other/module/...", wantErr: true},
+		{pattern: "../tools", wantErr: true},
+		{pattern: "example.com/app/tools", wantErr: true},
+		{pattern: filepath.Dir(root), wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ResolvePatterns([]string{test.pattern})
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ResolvePatterns(%q) should fail", test.pattern)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolvePatterns(%q) error = %v", test.pattern, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("ResolvePatterns(%q) = %v, want %v", test.pattern, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package generator
+
+import (
+	"fmt"
+)
+
+// aggregate merges the files sharing a variables file into a single file per package
+// The files are merged in order, the structs keep the order of their file
+// The files with a build constraint keep their own variables file
+func (g *Generator) aggregate(files []*FileData) ([]*FileData, error) {
+	aggregated := make([]*FileData, 0, len(files))
+	packages := make(map[string]*FileData)
+
+	for _, file := range files {
+		merged, exists := packages[file.Output]
+		if !exists {
+			if file.Output != PackagePath(file.Path) {
+				aggregated = append(aggregated, file)
+				continue
+			}
+			merged = &FileData{
+				Header:  file.Header,
+				Path:    file.Path,
+				Output:  file.Output,
+				Package: file.Package,
+			}
+			packages[file.Output] = merged
+			aggregated = append(aggregated, merged)
+		}
+
+		if merged.Package != file.Package {
+			return nil, fmt.Errorf("cannot generate %s: the files %s and %s belong to different packages", file.Output, merged.Path, file.Path)
+		}
+		merged.Sources = append(merged.Sources, file.Path)
+		merged.Structs = append(merged.Structs, file.Structs...)
+		merged.OptionsTypes = append(merged.OptionsTypes, file.OptionsTypes...)
+	}
+
+	return aggregated, nil
+}
@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Header is the first line of the generated files
+const Header = "// Code generated by tagsvar. DO NOT EDIT."
+
+// Status is the state of a variables file compared with the generated code
+type Status string
+
+const (
+	// StatusStale is a file whose content differs from the generated code
+	StatusStale Status = "stale"
+	// StatusMissing is a file that would be generated but does not exist
+	StatusMissing Status = "missing"
+	// StatusOrphaned is a generated file that has no source anymore
+	StatusOrphaned Status = "orphaned"
+)
+
+// Difference is a variables file that is not up to date
+type Difference struct {
+	// Path is the path of the variables file
+	Path string
+	// Status is the reason of the difference
+	Status Status
+}
+
+// Check compares the variables files generated from the parsed files with the files on disk
+// without writing anything
+// existing is the list of the variables files found on disk, used to find the orphaned files
+// The differences are sorted by path
+func (g *Generator) Check(files map[parser.FilePath]*parser.File, existing []string) ([]Difference, error) {
+	var differences []Difference
+
+	outputs, err := g.Render(files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stale and missing files
+	for _, output := range outputs {
+		path := filepath.Clean(output.Path)
+
+		content, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			differences = append(differences, Difference{Path: path, Status: StatusMissing})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(content, output.Code) {
+			differences = append(differences, Difference{Path: path, Status: StatusStale})
+		}
+	}
+
+	// Orphaned files
+	orphans, err := g.Orphans(files, existing)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range orphans {
+		differences = append(differences, Difference{Path: path, Status: StatusOrphaned})
+	}
+
+	sort.Slice(differences, func(i, j int) bool { return differences[i].Path < differences[j].Path })
+	return differences, nil
+}
+
+// Orphans returns the variables files generated by tagsvar whose source does not yield any struct anymore:
+// the source file has been deleted, or it has been parsed without any struct to process
+// The files that are not generated anymore because of the file per package option, and the
+// package files that are not generated anymore, are orphaned too
+// The sources that exist but were not parsed (ie: excluded by the build constraints) are not orphaned
+// existing is the list of the variables files found on disk
+func (g *Generator) Orphans(files map[parser.FilePath]*parser.File, existing []string) ([]string, error) {
+	var orphans []string
+	seen := make(map[string]bool, len(existing))
+
+	// Variables files that would be generated
+	planned := make(map[string]bool, len(files))
+	for _, file := range files {
+		if file != nil {
+			planned[filepath.Clean(g.outputPath(file))] = true
+		}
+	}
+
+	for _, path := range existing {
+		path = filepath.Clean(path)
+		if seen[path] || planned[path] {
+			continue
+		}
+		seen[path] = true
+
+		// Only the files generated by tagsvar are considered
+		generated, err := IsGenerated(path)
+		if err != nil {
+			return nil, err
+		}
+		if !generated {
+			continue
+		}
+
+		if fs.IsPackageFile(path) {
+			orphans = append(orphans, path)
+			continue
+		}
+
+		source := SourcePath(path)
+		if _, parsed := files[parser.FilePath(source)]; parsed {
+			orphans = append(orphans, path)
+			continue
+		}
+		if _, err := os.Stat(source); errors.Is(err, os.ErrNotExist) {
+			orphans = append(orphans, path)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// RemoveOrphans deletes the orphaned variables files
+func (g *Generator) RemoveOrphans(orphans []string) error {
+	for _, path := range orphans {
+		log.Info().Msgf("Deleting orphaned file %s", path)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsUpToDate checks if the variables file on disk has the generated code
+// A missing file is not up to date
+func IsUpToDate(output *Output) (bool, error) {
+	content, err := os.ReadFile(filepath.Clean(output.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(content, output.Code), nil
+}
+
+// IsGenerated checks if the file starts with the header of the generated files
+func IsGenerated(path string) (bool, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
+	return bytes.HasPrefix(content, []byte(Header+"\n")), nil
+}
@@ -0,0 +1,268 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-mods/tags"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/parser"
+)
+
+// Emission modes of the identifiers
+const (
+	// ModeFlat generates a constant per field and tag (ie: JsonAuthorName)
+	ModeFlat = "flat"
+	// ModeNamespace generates a struct value per struct and tag (ie: AuthorJson.Name)
+	ModeNamespace = "namespace"
+	// ModeBoth generates the constants and the struct values
+	ModeBoth = "both"
+)
+
+// FileData is the data given to the templates to generate a variables file
+type FileData struct {
+	// Header is the first line of the generated file
+	Header string
+	// Path is the path of the parsed file, or of the first parsed file of a package file
+	Path string
+	// Output is the path of the variables file
+	Output string
+	// Sources are the paths of the parsed files of a package file, nil for a variables file per parsed file
+	Sources []string
+	// Package is the package name of the variables file, the package of the parsed file unless an output package is configured
+	Package string
+	// BuildConstraint is the build constraint of the parsed file, without the //go:build prefix
+	BuildConstraint string
+	// Structs are the structs to generate, in the order of the parsed file
+	Structs []StructData
+	// OptionsTypes are the struct types of the options declared by the file (struct options mode)
+	OptionsTypes []OptionsTypeData
+	// File is the parsed file, nil for a package file
+	File *parser.File
+}
+
+// StructData is the data of a struct
+type StructData struct {
+	// Name is the name of the struct
+	Name string
+	// Comment is the comment of the struct, without the directive
+	Comment string
+	// Tags are the tag keys of the struct, in the order of their first use
+	Tags []TagData
+	// HasConsts is true if at least one tag has a name
+	HasConsts bool
+	// HasVars is true if at least one tag has options
+	HasVars bool
+	// Flat is true if the constants and the variables are generated (flat and both modes)
+	Flat bool
+	// Namespaced is true if the struct values are generated (namespace and both modes)
+	Namespaced bool
+	// HasHelpers is true if the lists, the maps and the lookup functions are generated
+	HasHelpers bool
+	// Struct is the parsed struct
+	Struct parser.Struct
+}
+
+// TagData is the data of a tag key of a struct
+type TagData struct {
+	// Key is the tag key (ie: json)
+	Key string
+	// Namespace is the name of the struct value holding the tag names (ie: AuthorJson)
+	Namespace string
+	// OptionsNamespace is the name of the struct value holding the tag options (ie: AuthorJsonOptions)
+	OptionsNamespace string
+	// Consts are the constants of the tag names, one per field
+	Consts []ConstData
+	// Vars are the variables of the tag options, one per field
+	Vars []VarData
+	// Helpers are the lists, the maps and the lookup functions of the tag names, nil if not generated
+	Helpers *HelpersData
+}
+
+// ConstData is a constant holding the name of a tag
+type ConstData struct {
+	// Name is the name of the constant
+	Name string
+	// Member is the name of the field in the struct value of the namespace
+	Member string
+	// Value is the tag name
+	Value string
+	// Field is the parsed field
+	Field parser.Field
+	// Tag is the parsed tag
+	Tag tags.Tag
+}
+
+// VarData is a variable holding the options of a tag
+type VarData struct {
+	// Name is the name of the variable
+	Name string
+	// Member is the name of the field in the struct value of the namespace
+	Member string
+	// Type is the struct type of the options (struct options mode), empty for a map
+	Type string
+	// Options are the options of the tag, in the order of the tag
+	Options []OptionData
+	// Field is the parsed field
+	Field parser.Field
+	// Tag is the parsed tag
+	Tag tags.Tag
+}
+
+// OptionData is an option of a tag
+type OptionData struct {
+	// Key is the option key
+	Key string
+	// Value is the option value, empty if the option has no value
+	Value string
+	// HasValue is true if the option has a value (ie: type:uuid)
+	HasValue bool
+	// Type is the Go type of the value (string, int, float64 or bool)
+	Type string
+	// Literal is the Go literal of the value (ie: "uuid", 255, true)
+	Literal string
+	// Member is the name of the field in the struct type of the options
+	Member string
+}
+
+// newFileData builds the data of a parsed file
+func (g *Generator) newFileData(file *parser.File) (*FileData, error) {
+	pkg, err := outputPackage(file.Package)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &FileData{
+		Header:          Header,
+		Path:            string(file.Path),
+		Output:          g.outputPath(file),
+		Package:         pkg,
+		BuildConstraint: file.BuildConstraint,
+		File:            file,
+	}
+	for _, s := range file.Structs {
+		structData, err := g.newStructData(s)
+		if err != nil {
+			return nil, err
+		}
+		data.Structs = append(data.Structs, structData)
+	}
+	return data, nil
+}
+
+// newStructData builds the data of a struct
+// The excluded fields are skipped
+func (g *Generator) newStructData(s parser.Struct) (StructData, error) {
+	data := StructData{
+		Name:    s.Name,
+		Comment: s.Comment,
+		Struct:  s,
+	}
+
+	mode, err := structMode(s)
+	if err != nil {
+		return data, err
+	}
+	data.Flat = mode == ModeFlat || mode == ModeBoth
+	data.Namespaced = mode == ModeNamespace || mode == ModeBoth
+
+	helpers, err := structHelpers(s)
+	if err != nil {
+		return data, err
+	}
+
+	for _, tk := range s.TagKeys {
+		namespace := g.namespaceName(s, tk)
+		tagData := TagData{Key: tk, Namespace: namespace, OptionsNamespace: namespace + "Options"}
+		for _, f := range s.Fields {
+			if f.Exclude {
+				continue
+			}
+			for _, t := range f.Tags {
+				if t.Key != tk {
+					continue
+				}
+				if t.Name != "" {
+					name, err := g.constName(s, f, t)
+					if err != nil {
+						return data, err
+					}
+					tagData.Consts = append(tagData.Consts, ConstData{
+						Name:   name,
+						Member: camelCase(f.Identifier()),
+						Value:  t.Name,
+						Field:  f,
+						Tag:    t,
+					})
+				}
+				if len(t.Options) > 0 {
+					name, err := g.varName(s, f, t)
+					if err != nil {
+						return data, err
+					}
+					tagData.Vars = append(tagData.Vars, VarData{
+						Name:    name,
+						Member:  camelCase(f.Identifier()),
+						Options: newOptionsData(t.Options),
+						Field:   f,
+						Tag:     t,
+					})
+				}
+			}
+		}
+		if helpers && len(tagData.Consts) > 0 {
+			tagData.Helpers = newHelpersData(tagData)
+			data.HasHelpers = true
+		}
+		data.HasConsts = data.HasConsts || len(tagData.Consts) > 0
+		data.HasVars = data.HasVars || len(tagData.Vars) > 0
+		data.Tags = append(data.Tags, tagData)
+	}
+
+	return data, nil
+}
+
+// newOptionsData builds the data of the options of a tag
+func newOptionsData(options []*tags.Option) []OptionData {
+	data := make([]OptionData, 0, len(options))
+	for _, o := range options {
+		option := OptionData{Key: o.Key}
+		if o.Value != nil {
+			option.Value = fmt.Sprintf("%v", o.Value)
+			option.HasValue = true
+		}
+		data = append(data, option)
+	}
+	return data
+}
+
+// constName returns the name of the constant holding the tag name
+func (g *Generator) constName(s parser.Struct, f parser.Field, t tags.Tag) (string, error) {
+	return g.identifier(t.Key, s.Name, f.Identifier())
+}
+
+// varName returns the name of the variable holding the tag options
+func (g *Generator) varName(s parser.Struct, f parser.Field, t tags.Tag) (string, error) {
+	name, err := g.constName(s, f, t)
+	return name + "Options", err
+}
+
+// structMode returns the emission mode of the struct
+// The mode option of the directive overrides the mode of the configuration
+func structMode(s parser.Struct) (string, error) {
+	mode := config.C.Mode
+	if m, ok := s.Options["mode"]; ok {
+		mode = m
+	}
+	switch mode {
+	case "":
+		return ModeFlat, nil
+	case ModeFlat, ModeNamespace, ModeBoth:
+		return mode, nil
+	}
+
+	err := errors.New("unknown mode " + mode + " of struct " + s.Name)
+	if s.Pos.IsValid() {
+		err = fmt.Errorf("%s: %w", s.Pos, err)
+	}
+	return "", err
+}
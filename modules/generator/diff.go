@@ -0,0 +1,45 @@
+package generator
+
+import (
+	"errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Diff returns the unified diff between the variables file on disk and the generated code
+// A missing file is compared as an empty file, an empty string is returned if nothing changes
+func (g *Generator) Diff(output *Output) (string, error) {
+	path := filepath.Clean(output.Path)
+
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	from := path
+	if errors.Is(err, os.ErrNotExist) {
+		from = os.DevNull
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(current),
+		B:        splitLines(output.Code),
+		FromFile: from,
+		ToFile:   path,
+		Context:  3,
+	})
+}
+
+// splitLines splits the content into lines, keeping the line endings
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
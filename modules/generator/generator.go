@@ -1,208 +1,294 @@
 package generator
 
 import (
-	"bytes"
 	"fmt"
-	"github.com/go-mods/tags"
 	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/fs"
 	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/go-mods/tagsvar/modules/workers"
 	"github.com/rs/zerolog/log"
-	"github.com/stoewer/go-strcase"
 	"go/format"
+	"go/token"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 )
 
 type Generator struct {
+	// tmpl holds the templates used to generate the code
+	tmpl *template.Template
+	// namingTmpl holds the template of the identifiers
+	namingTmpl *template.Template
+	// mu guards the lazy loading of the templates when the files are generated concurrently
+	mu sync.Mutex
+	// jobs is the number of files generated and written concurrently
+	jobs int
 }
 
 // NewGenerator creates an instance of Generator
 func NewGenerator() *Generator {
-	return &Generator{}
+	return &Generator{jobs: workers.Jobs()}
+}
+
+// Output is a variables file generated in memory
+type Output struct {
+	// Path is the path of the variables file
+	Path string
+	// Source is the path of the parsed file, or of the first parsed file of a package file
+	Source parser.FilePath
+	// Code is the generated code
+	Code []byte
 }
 
 // Generate generates the variables files
 func (g *Generator) Generate(files map[parser.FilePath]*parser.File) error {
-	// Loop through the files
-	for _, file := range files {
-		// Generate the file
-		if file != nil {
-			log.Info().Msgf("Generating file for %s", string(file.Path))
-			err := g.generateFile(file)
-			if err != nil {
-				return err
-			}
-		}
+	// Generate the code in memory
+	outputs, err := g.Render(files)
+	if err != nil {
+		return err
 	}
-	return nil
-}
 
-// generateFile generates the variables file
-func (g *Generator) generateFile(file *parser.File) error {
+	// Write the files
+	return g.Write(outputs)
+}
 
-	// Construct the file path where the variables file will be generated
-	filePath := string(file.Path)
-	filePath = strings.TrimSuffix(filePath, filepath.Ext(filePath))
-	filePath = config.C.Prefix + filePath + config.C.Suffix + ".go"
+// Render generates the code of the variables files in memory
+// The identifiers must be unique in each package, see resolveCollisions
+// The outputs are sorted by path
+func (g *Generator) Render(files map[parser.FilePath]*parser.File) ([]*Output, error) {
+	// Sort the files, the first declaration of an identifier wins
+	paths := make([]string, 0, len(files))
+	for path, file := range files {
+		if file != nil {
+			paths = append(paths, string(path))
+		}
+	}
+	sort.Strings(paths)
 
-	// Generate the code to write to the file
-	genCode, err := g.generateCode(file)
-	if err != nil {
+	// Build the data of the files
+	data := make([]*FileData, len(paths))
+	err := workers.Run(len(paths), g.jobs, func(i int) error {
+		var err error
+		data[i], err = g.newFileData(files[parser.FilePath(paths[i])])
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create the file
-	genFile, err := os.Create(filepath.Clean(filePath))
+	// Type the options and check the identifiers
+	err = g.resolveOptions(data)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	err = g.resolveCollisions(data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Write the code to the file
-	_, err = genFile.Write(genCode)
+	// Aggregate the files of the packages
+	if config.C.PerPackage {
+		data, err = g.aggregate(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Generate the code of the files
+	outputs := make([]*Output, len(data))
+	err = workers.Run(len(data), g.jobs, func(i int) error {
+		genCode, err := g.generateData(data[i])
+		if err != nil {
+			return err
+		}
+		outputs[i] = &Output{
+			Path:   data[i].Output,
+			Source: parser.FilePath(data[i].Path),
+			Code:   genCode,
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Path < outputs[j].Path })
+	return outputs, nil
 }
 
-// generateCode generates the code for the variables file
-func (g *Generator) generateCode(file *parser.File) ([]byte, error) {
-	if file == nil {
-		return nil, nil
-	}
+// Write writes the variables files generated in memory
+// The files are written concurrently, the error of the first file in the list is returned
+// The files that are up to date are not written, so that they keep their modification time
+func (g *Generator) Write(outputs []*Output) error {
+	upToDate := make([]bool, len(outputs))
+	written := make([]bool, len(outputs))
+	err := workers.Run(len(outputs), g.jobs, func(i int) error {
+		var err error
+		upToDate[i], err = IsUpToDate(outputs[i])
+		if err != nil || upToDate[i] {
+			return err
+		}
+		if err = g.writeFile(outputs[i]); err != nil {
+			return err
+		}
+		written[i] = true
+		return nil
+	})
 
-	// Buffer to write the code to
-	genCode := bytes.Buffer{}
-	genCode.WriteString("// Code generated by tagsvar. DO NOT EDIT.\n\n")
-	genCode.WriteString("package " + file.Package + "\n\n")
-	genCode.WriteString("// File: " + string(file.Path) + "\n")
+	// The files are logged in order, whatever the scheduling
+	for i, output := range outputs {
+		switch {
+		case upToDate[i]:
+			log.Debug().Msgf("Variables file %s is up to date", output.Path)
+		case !written[i]:
+			continue
+		case fs.IsPackageFile(output.Path):
+			log.Info().Msgf("Generating file for the package in %s", filepath.Dir(output.Path))
+		default:
+			log.Info().Msgf("Generating file for %s", string(output.Source))
+		}
+	}
+	return err
+}
 
-	// loop through file.Structs
-	for _, s := range file.Structs {
-		// Title
-		g.writeTitle(&genCode, s)
+// generateFile generates the variables file
+func (g *Generator) generateFile(file *parser.File) error {
+	// Generate the code to write to the file
+	output, err := g.render(file)
+	if err != nil {
+		return err
+	}
 
-		// Const
-		g.writeConst(&genCode, s)
+	return g.writeFile(output)
+}
 
-		// Var
-		g.writeVars(&genCode, s)
+// render generates the variables file in memory
+func (g *Generator) render(file *parser.File) (*Output, error) {
+	// Generate the code to write to the file
+	genCode, err := g.generateCode(file)
+	if err != nil {
+		return nil, err
 	}
 
-	return format.Source(genCode.Bytes())
+	return &Output{
+		Path:   OutputPath(string(file.Path)),
+		Source: file.Path,
+		Code:   genCode,
+	}, nil
 }
 
-// writeTitle writes the title of the struct
-func (g *Generator) writeTitle(genCode *bytes.Buffer, s parser.Struct) {
-	genCode.WriteString("\n")
-	genCode.WriteString("// Struct: " + s.Name + "\n")
-	if s.Comment != "" {
-		genCode.WriteString("// " + s.Comment + "\n")
+// writeFile writes the variables file
+// The output directory is created if needed
+func (g *Generator) writeFile(output *Output) error {
+	if err := os.MkdirAll(filepath.Dir(filepath.Clean(output.Path)), 0755); err != nil {
+		return err
 	}
+	return os.WriteFile(filepath.Clean(output.Path), output.Code, 0644)
 }
 
-// writeConst writes the const variable
-func (g *Generator) writeConst(genCode *bytes.Buffer, s parser.Struct) {
-	// at least one of the tag name must be set
-	hasName := false
-	for _, f := range s.Fields {
-		for _, t := range f.Tags {
-			if t.Name != "" {
-				hasName = true
-				break
-			}
-		}
+// OutputPath returns the path of the variables file generated for a source file
+// The prefix and the suffix of the configuration are added to the file name,
+// and the file is placed in the output directory of the configuration
+func OutputPath(source string) string {
+	dir, name := filepath.Split(source)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return filepath.Join(dir, config.C.OutDir, config.C.Prefix+name+config.C.Suffix+".go")
+}
+
+// outputPath returns the path of the variables file of a parsed file
+// With a file per package, the parsed files without build constraint share the package file
+func (g *Generator) outputPath(file *parser.File) string {
+	if config.C.PerPackage && file.BuildConstraint == "" {
+		return PackagePath(string(file.Path))
 	}
+	return OutputPath(string(file.Path))
+}
 
-	// if no tag name is set, do not generate the const
-	if !hasName {
-		return
-	}
-
-	// Const's variables organised by tag key
-	genCode.WriteString("const (\n")
-	for _, tk := range s.TagKeys {
-		genCode.WriteString("// Tag: " + tk + "\n")
-		for _, f := range s.Fields {
-			for _, tag := range f.Tags {
-				if tag.Key == tk {
-					constName := g.generateConstName(s, f, tag)
-					if constName != "" {
-						genCode.WriteString(constName + "\n")
-					}
-				}
-			}
-		}
-		genCode.WriteString("\n")
+// PackagePath returns the path of the variables file of the package of a source file
+func PackagePath(source string) string {
+	return filepath.Join(filepath.Dir(source), config.C.OutDir, config.C.PackageFile)
+}
+
+// SourcePath returns the path of the source file of a variables file
+// It is the reverse of OutputPath
+func SourcePath(output string) string {
+	dir, name := filepath.Split(output)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = strings.TrimPrefix(name, config.C.Prefix)
+	name = strings.TrimSuffix(name, config.C.Suffix)
+
+	// The variables files of the output directory are generated from the parent directory
+	dir = filepath.Clean(dir)
+	if outDir := filepath.Clean(config.C.OutDir); outDir != "." && strings.HasSuffix(dir, string(filepath.Separator)+outDir) {
+		dir = strings.TrimSuffix(dir, string(filepath.Separator)+outDir)
 	}
-	genCode.WriteString(")\n")
+	return filepath.Join(dir, name+".go")
 }
 
-// writeVars writes the vars variables from the tag options
-func (g *Generator) writeVars(genCode *bytes.Buffer, s parser.Struct) {
-	// at least one of the tag options must be set
-	hasOptions := false
-	for _, f := range s.Fields {
-		for _, t := range f.Tags {
-			if len(t.Options) > 0 {
-				hasOptions = true
-				break
-			}
+// outputPackage returns the package name of the variables files generated for a source package
+// The variables files generated in the output directory have their own package
+func outputPackage(pkg string) (string, error) {
+	if config.C.OutDir == "" {
+		if config.C.OutPackage != "" && config.C.OutPackage != pkg {
+			return "", fmt.Errorf("the output package %s requires an output directory", config.C.OutPackage)
 		}
+		return pkg, nil
 	}
 
-	// if no tag options are set, do not generate the vars
-	if !hasOptions {
-		return
-	}
-
-	// Var's variables organised by tag key
-	genCode.WriteString("var (\n")
-	for _, tk := range s.TagKeys {
-		genCode.WriteString("// Tag: " + tk + "\n")
-		for _, f := range s.Fields {
-			for _, tag := range f.Tags {
-				if tag.Key == tk {
-					varOptions := g.generateVarOptions(s, f, tag)
-					if varOptions != "" {
-						genCode.WriteString(varOptions + "\n")
-					}
-				}
-			}
-		}
-		genCode.WriteString("\n")
+	// The output directory must be inside the directory of the source package
+	outDir := filepath.Clean(config.C.OutDir)
+	if filepath.IsAbs(outDir) || outDir == "." || outDir == ".." || strings.HasPrefix(outDir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the output directory %s must be a subdirectory of the package directory", config.C.OutDir)
 	}
-	genCode.WriteString(")\n")
-}
 
-// generateConstName generates the variable from the tag name
-func (g *Generator) generateConstName(s parser.Struct, f parser.Field, t tags.Tag) string {
-	if t.Name == "" {
-		return ""
+	name := config.C.OutPackage
+	if name == "" {
+		name = filepath.Base(outDir)
+	}
+	if !token.IsIdentifier(name) {
+		return "", fmt.Errorf("invalid output package name %s", name)
 	}
-	return strcase.UpperCamelCase(t.Key) + strcase.UpperCamelCase(s.Name) + strcase.UpperCamelCase(f.Name) + ` = "` + t.Name + `"`
+	return name, nil
 }
 
-// generateVarOptions generates the variable from the tag options
-func (g *Generator) generateVarOptions(s parser.Struct, f parser.Field, t tags.Tag) string {
-	if len(t.Options) == 0 {
-		return ""
+// generateCode generates the code for the variables file
+func (g *Generator) generateCode(file *parser.File) ([]byte, error) {
+	if file == nil {
+		return nil, nil
 	}
 
-	options := strcase.UpperCamelCase(t.Key) + strcase.UpperCamelCase(s.Name) + strcase.UpperCamelCase(f.Name) + `Options = map[string]any{` + "\n"
+	data, err := g.newFileData(file)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, o := range t.Options {
-		if o.Value != nil {
-			options = options + fmt.Sprintf(`"%s": "%v",`, o.Key, o.Value)
-		} else {
-			options = options + `"` + o.Key + `": nil, `
-		}
-		options = options + "\n"
+	// Type the options and check the identifiers
+	err = g.resolveOptions([]*FileData{data})
+	if err != nil {
+		return nil, err
+	}
+	err = g.resolveCollisions([]*FileData{data})
+	if err != nil {
+		return nil, err
 	}
 
-	options = options + `}`
+	return g.generateData(data)
+}
 
-	return options
+// generateData generates the code from the data of the file
+// The code is generated by the templates, then formatted
+func (g *Generator) generateData(data *FileData) ([]byte, error) {
+	genCode, err := g.execute(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate the code of %s: %w", data.Path, err)
+	}
+
+	formatted, err := format.Source(genCode)
+	if err != nil {
+		return nil, fmt.Errorf("cannot format the code generated for %s: %w", data.Path, err)
+	}
+	return formatted, nil
 }
@@ -1,7 +1,15 @@
 package generator
 
 import (
+	"errors"
+	"github.com/go-mods/tags"
+	"github.com/go-mods/tagsvar/modules/config"
 	"github.com/go-mods/tagsvar/modules/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -37,3 +45,708 @@ func TestGenerator_generateFile(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerator_generateFileDialects(t *testing.T) {
+	dialects := config.C.Dialects
+	defer func() { config.C.Dialects = dialects }()
+	config.C.Dialects = true
+
+	// Parse the file with the dialects
+	parsed, err := parser.NewParser().ParseFile("../../.testdata/dialects.go")
+	if err != nil || parsed == nil {
+		t.Fatalf("ParseFile() got = %v, error = %v", parsed, err)
+	}
+
+	// Generate the file
+	if err = NewGenerator().generateFile(parsed); err != nil {
+		t.Errorf("generateFile() error = %v", err)
+	}
+}
+
+func TestGenerator_generateCodeFieldDirective(t *testing.T) {
+	file := &parser.File{
+		Path:    "user.go",
+		Package: "testdata",
+		Structs: []parser.Struct{
+			{
+				Name:    "User",
+				TagKeys: []string{"json", "gorm"},
+				Fields: []parser.Field{
+					{
+						Name:  "ID",
+						Alias: "PrimaryKey",
+						Tags: []tags.Tag{
+							{Key: "json", Name: "id"},
+							{Key: "gorm", Name: "id", Options: []*tags.Option{{Key: "primary_key"}}},
+						},
+					},
+					{
+						Name:    "Password",
+						Exclude: true,
+						Tags: []tags.Tag{
+							{Key: "json", Name: "password"},
+							{Key: "gorm", Name: "password", Options: []*tags.Option{{Key: "not null"}}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	code, err := NewGenerator().generateCode(file)
+	if err != nil {
+		t.Fatalf("generateCode() error = %v", err)
+	}
+
+	for _, want := range []string{"JsonUserPrimaryKey", "GormUserPrimaryKeyOptions"} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generateCode() should contain %s:\n%s", want, code)
+		}
+	}
+	for _, unwanted := range []string{"UserId", "Password"} {
+		if strings.Contains(string(code), unwanted) {
+			t.Errorf("generateCode() should not contain %s:\n%s", unwanted, code)
+		}
+	}
+}
+
+func TestGenerator_Check(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	file := func(name string, structName string) *parser.File {
+		return &parser.File{
+			Path:    parser.FilePath(filepath.Join(dir, name)),
+			Package: "models",
+			Structs: []parser.Struct{{
+				Name:    structName,
+				TagKeys: []string{"json"},
+				Fields:  []parser.Field{{Name: "ID", Tags: []tags.Tag{{Key: "json", Name: "id"}}}},
+			}},
+		}
+	}
+
+	g := NewGenerator()
+	files := map[parser.FilePath]*parser.File{
+		parser.FilePath(filepath.Join(dir, "user.go")):    file("user.go", "User"),
+		parser.FilePath(filepath.Join(dir, "post.go")):    file("post.go", "Post"),
+		parser.FilePath(filepath.Join(dir, "tag.go")):     file("tag.go", "Tag"),
+		parser.FilePath(filepath.Join(dir, "comment.go")): nil,
+	}
+	code, err := g.generateCode(file("user.go", "User"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated := Header + "\n\npackage models\n"
+	existing := []string{
+		write("user.vars.go", string(code)),
+		write("post.vars.go", generated),
+		write("comment.vars.go", generated),
+		write("deleted.vars.go", generated),
+		write("manual.vars.go", "package models\n"),
+		write("user_windows.vars.go", generated),
+	}
+	// Source not parsed, ie: excluded by the build constraints
+	write("user_windows.go", "package models\n")
+
+	differences, err := g.Check(files, existing)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	want := []Difference{
+		{Path: filepath.Join(dir, "comment.vars.go"), Status: StatusOrphaned},
+		{Path: filepath.Join(dir, "deleted.vars.go"), Status: StatusOrphaned},
+		{Path: filepath.Join(dir, "post.vars.go"), Status: StatusStale},
+		{Path: filepath.Join(dir, "tag.vars.go"), Status: StatusMissing},
+	}
+	if !reflect.DeepEqual(differences, want) {
+		t.Errorf("Check() = %v, want %v", differences, want)
+	}
+}
+
+func TestGenerator_writeFile(t *testing.T) {
+	dir := t.TempDir()
+	g := NewGenerator()
+
+	// A new file is as readable as a file created by the editors
+	reference, err := os.Create(filepath.Join(dir, "reference.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = reference.Close()
+	want, err := os.Stat(reference.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "user.vars.go")
+	if err := g.writeFile(&Output{Path: created, Code: []byte("package models\n")}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0444 != want.Mode().Perm()&0444 {
+		t.Errorf("writeFile() mode = %v, want the read permissions of %v", info.Mode().Perm(), want.Mode().Perm())
+	}
+
+	// An existing file keeps its mode
+	existing := filepath.Join(dir, "post.vars.go")
+	if err := os.WriteFile(existing, []byte("package models\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.writeFile(&Output{Path: existing, Code: []byte("package models\n\n")}); err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("writeFile() mode = %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	prefix, suffix := config.C.Prefix, config.C.Suffix
+	defer func() { config.C.Prefix, config.C.Suffix = prefix, suffix }()
+
+	config.C.Prefix, config.C.Suffix = "gen_", ".vars"
+	if got, want := OutputPath(filepath.Join("models", "user.go")), filepath.Join("models", "gen_user.vars.go"); got != want {
+		t.Errorf("OutputPath() = %s, want %s", got, want)
+	}
+	if got, want := SourcePath(filepath.Join("models", "gen_user.vars.go")), filepath.Join("models", "user.go"); got != want {
+		t.Errorf("SourcePath() = %s, want %s", got, want)
+	}
+}
+
+func TestGenerator_Diff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user.vars.go")
+	if err := os.WriteFile(path, []byte("package models\n\nconst A = 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator()
+
+	diff, err := g.Diff(&Output{Path: path, Code: []byte("package models\n\nconst A = 2\n")})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	for _, want := range []string{"--- " + path, "+++ " + path, "-const A = 1\n", "+const A = 2\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Diff() should contain %q:\n%s", want, diff)
+		}
+	}
+
+	diff, err = g.Diff(&Output{Path: path, Code: []byte("package models\n\nconst A = 1\n")})
+	if err != nil || diff != "" {
+		t.Errorf("Diff() = %q, %v, want no difference", diff, err)
+	}
+
+	diff, err = g.Diff(&Output{Path: filepath.Join(dir, "post.vars.go"), Code: []byte("package models\n")})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !strings.Contains(diff, "--- "+os.DevNull) || !strings.Contains(diff, "+package models\n") {
+		t.Errorf("Diff() of a missing file:\n%s", diff)
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	dir := t.TempDir()
+
+	var tests = []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"generated.vars.go", Header + "\n\npackage models\n", true},
+		{"manual.vars.go", "package models\n", false},
+		{"other.vars.go", "// Code generated by another tool. DO NOT EDIT.\n\npackage models\n", false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := IsGenerated(path)
+		if err != nil {
+			t.Errorf("IsGenerated(%s) error = %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("IsGenerated(%s) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGenerator_generateCodeTemplate(t *testing.T) {
+	tmpl := filepath.Join(t.TempDir(), "custom.tmpl")
+	err := os.WriteFile(tmpl, []byte(`{{define "const"}}{{ .Name }} = {{ quote .Value }} // {{ .Field.Name }}{{end}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := config.C.Template
+	defer func() { config.C.Template = template }()
+	config.C.Template = tmpl
+
+	file := &parser.File{
+		Path:    "user.go",
+		Package: "models",
+		Structs: []parser.Struct{{
+			Name:    "User",
+			TagKeys: []string{"json"},
+			Fields:  []parser.Field{{Name: "ID", Tags: []tags.Tag{{Key: "json", Name: "id"}}}},
+		}},
+	}
+
+	code, err := NewGenerator().generateCode(file)
+	if err != nil {
+		t.Fatalf("generateCode() error = %v", err)
+	}
+	for _, want := range []string{Header, "// Struct: User", `JsonUserId = "id" // ID`} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generateCode() should contain %s:\n%s", want, code)
+		}
+	}
+}
+
+func TestGenerator_generateCodeQuote(t *testing.T) {
+	mode, helpers := config.C.Mode, config.C.Helpers
+	defer func() { config.C.Mode, config.C.Helpers = mode, helpers }()
+	config.C.Mode, config.C.Helpers = "both", true
+
+	// The tag names and the option keys can contain quotes and backslashes
+	file := &parser.File{
+		Path:    "user.go",
+		Package: "models",
+		Structs: []parser.Struct{{
+			Name:    "User",
+			TagKeys: []string{"json", "gorm"},
+			Fields: []parser.Field{{Name: "ID", Tags: []tags.Tag{
+				{Key: "json", Name: `a"b\c`},
+				{Key: "gorm", Name: "id", Options: []*tags.Option{{Key: `default:"x"`}}},
+			}}},
+		}},
+	}
+
+	code, err := NewGenerator().generateCode(file)
+	if err != nil {
+		t.Fatalf("generateCode() error = %v", err)
+	}
+	for _, want := range []string{`JsonUserId = "a\"b\\c"`, `Id: "a\"b\\c"`, `"ID": "a\"b\\c"`, `"a\"b\\c": "ID"`, `"default:\"x\"": nil`} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generateCode() should contain %s:\n%s", want, code)
+		}
+	}
+}
+
+func TestGenerator_identifier(t *testing.T) {
+	naming, initialisms, unexported := config.C.Naming, config.C.Initialisms, config.C.Unexported
+	defer func() { config.C.Naming, config.C.Initialisms, config.C.Unexported = naming, initialisms, unexported }()
+
+	var tests = []struct {
+		name        string
+		naming      string
+		initialisms bool
+		unexported  bool
+		want        string
+	}{
+		{"default", DefaultNaming, false, false, "JsonBlogAuthorId"},
+		{"order", "{{.Struct}}{{.Field}}{{.Tag}}", false, false, "BlogAuthorIdJson"},
+		{"initialisms", DefaultNaming, true, false, "JSONBlogAuthorID"},
+		{"unexported", DefaultNaming, false, true, "jsonBlogAuthorId"},
+		{"unexported initialisms", DefaultNaming, true, true, "jsonBlogAuthorID"},
+		{"unexported struct first", "{{.Struct}}{{.Field}}", true, true, "blogAuthorID"},
+	}
+	for _, test := range tests {
+		config.C.Naming, config.C.Initialisms, config.C.Unexported = test.naming, test.initialisms, test.unexported
+
+		got, err := NewGenerator().identifier("json", "blog_author", "ID")
+		if err != nil {
+			t.Errorf("%s: identifier() error = %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: identifier() = %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	config.C.Naming = "{{.Unknown}}"
+	if _, err := NewGenerator().identifier("json", "User", "ID"); err == nil {
+		t.Errorf("identifier() should fail with an unknown part")
+	}
+}
+
+func TestGenerator_RenderCollisions(t *testing.T) {
+	disambiguate := config.C.Disambiguate
+	defer func() { config.C.Disambiguate = disambiguate }()
+
+	field := func(name string, line int) parser.Field {
+		return parser.Field{
+			Name: name,
+			Tags: []tags.Tag{{Key: "json", Name: "x"}},
+			Pos:  token.Position{Filename: "user.go", Line: line, Column: 2},
+		}
+	}
+	files := map[parser.FilePath]*parser.File{
+		"user.go": {
+			Path:    "user.go",
+			Package: "models",
+			Structs: []parser.Struct{
+				{Name: "User", TagKeys: []string{"json"}, Fields: []parser.Field{field("IdX", 4)}},
+				{Name: "UserId", TagKeys: []string{"json"}, Fields: []parser.Field{field("X", 8)}},
+			},
+		},
+	}
+
+	config.C.Disambiguate = false
+	_, err := NewGenerator().Render(files)
+	var collision *Collision
+	if !errors.As(err, &collision) {
+		t.Fatalf("Render() error = %v, want a collision", err)
+	}
+	for _, want := range []string{"JsonUserIdX redeclared", "user.go:4:2 (User.IdX)", "user.go:8:2 (UserId.X)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Render() error should contain %s: %v", want, err)
+		}
+	}
+
+	config.C.Disambiguate = true
+	outputs, err := NewGenerator().Render(files)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, want := range []string{"JsonUserIdX ", "JsonUserIdX2 "} {
+		if !strings.Contains(string(outputs[0].Code), want) {
+			t.Errorf("Render() should contain %s:\n%s", want, outputs[0].Code)
+		}
+	}
+}
+
+func TestGenerator_generateCodeMode(t *testing.T) {
+	mode, initialisms := config.C.Mode, config.C.Initialisms
+	defer func() { config.C.Mode, config.C.Initialisms = mode, initialisms }()
+	config.C.Initialisms = true
+
+	file := func(options map[string]string) *parser.File {
+		return &parser.File{
+			Path:    "author.go",
+			Package: "models",
+			Structs: []parser.Struct{{
+				Name:    "Author",
+				TagKeys: []string{"json", "gorm"},
+				Options: options,
+				Fields: []parser.Field{
+					{Name: "ID", Tags: []tags.Tag{
+						{Key: "json", Name: "id"},
+						{Key: "gorm", Name: "id", Options: []*tags.Option{{Key: "primary_key"}}},
+					}},
+					{Name: "Name", Tags: []tags.Tag{{Key: "json", Name: "name"}}},
+				},
+			}},
+		}
+	}
+
+	var tests = []struct {
+		name     string
+		mode     string
+		options  map[string]string
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "flat",
+			mode:     ModeFlat,
+			want:     []string{"JSONAuthorID", "GormAuthorIDOptions"},
+			unwanted: []string{"AuthorJSON"},
+		},
+		{
+			name: "namespace",
+			mode: ModeNamespace,
+			want: []string{
+				"var AuthorJSON = struct {\n\tID   string\n\tName string\n}{\n\tID:   \"id\",\n\tName: \"name\",\n}",
+				"var AuthorGormOptions = struct {\n\tID map[string]any\n}{\n\tID: map[string]any{\n\t\t\"primary_key\": nil,\n\t},\n}",
+			},
+			unwanted: []string{"JSONAuthorID", "const ("},
+		},
+		{
+			name: "both",
+			mode: ModeBoth,
+			want: []string{"JSONAuthorID", "GormAuthorIDOptions", "var AuthorJSON = struct", "var AuthorGorm = struct"},
+		},
+		{
+			name:     "directive",
+			mode:     ModeFlat,
+			options:  map[string]string{"mode": ModeNamespace},
+			want:     []string{"var AuthorJSON = struct"},
+			unwanted: []string{"JSONAuthorID"},
+		},
+	}
+	for _, test := range tests {
+		config.C.Mode = test.mode
+
+		code, err := NewGenerator().generateCode(file(test.options))
+		if err != nil {
+			t.Errorf("%s: generateCode() error = %v", test.name, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(string(code), want) {
+				t.Errorf("%s: generateCode() should contain %s:\n%s", test.name, want, code)
+			}
+		}
+		for _, unwanted := range test.unwanted {
+			if strings.Contains(string(code), unwanted) {
+				t.Errorf("%s: generateCode() should not contain %s:\n%s", test.name, unwanted, code)
+			}
+		}
+	}
+
+	config.C.Mode = "unknown"
+	if _, err := NewGenerator().generateCode(file(nil)); err == nil {
+		t.Errorf("generateCode() should fail with an unknown mode")
+	}
+}
+
+func TestGenerator_generateCodeHelpers(t *testing.T) {
+	helpers := config.C.Helpers
+	defer func() { config.C.Helpers = helpers }()
+
+	file := func(options map[string]string) *parser.File {
+		return &parser.File{
+			Path:    "author.go",
+			Package: "models",
+			Structs: []parser.Struct{{
+				Name:    "Author",
+				TagKeys: []string{"json", "gorm"},
+				Options: options,
+				Fields: []parser.Field{
+					{Name: "ID", Tags: []tags.Tag{{Key: "json", Name: "id"}, {Key: "gorm", Name: "id"}}},
+					{Name: "Name", Tags: []tags.Tag{{Key: "json", Name: "name"}, {Key: "gorm", Name: "name"}}},
+					{Name: "Nickname", Tags: []tags.Tag{{Key: "json", Name: "name"}}},
+				},
+			}},
+		}
+	}
+
+	config.C.Helpers = true
+	code, err := NewGenerator().generateCode(file(nil))
+	if err != nil {
+		t.Fatalf("generateCode() error = %v", err)
+	}
+	for _, want := range []string{
+		"var AuthorJsonFields = []string{\n\t\"id\",\n\t\"name\",\n}",
+		"var AuthorJsonByField = map[string]string{\n\t\"ID\":       \"id\",\n\t\"Name\":     \"name\",\n\t\"Nickname\": \"name\",\n}",
+		"var AuthorJsonByName = map[string]string{\n\t\"id\":   \"ID\",\n\t\"name\": \"Name\",\n}",
+		"func AuthorJsonName(field string) (string, bool) {",
+		"func AuthorJsonField(name string) (string, bool) {",
+		"func AuthorGormColumn(field string) (string, bool) {",
+		"func AuthorGormField(name string) (string, bool) {",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generateCode() should contain %s:\n%s", want, code)
+		}
+	}
+
+	config.C.Helpers = false
+	for options, want := range map[string]bool{"": false, "true": true} {
+		var structOptions map[string]string
+		if options != "" {
+			structOptions = map[string]string{"helpers": options}
+		}
+		code, err := NewGenerator().generateCode(file(structOptions))
+		if err != nil {
+			t.Fatalf("generateCode() error = %v", err)
+		}
+		if got := strings.Contains(string(code), "AuthorJsonFields"); got != want {
+			t.Errorf("generateCode() with helpers option %q generates the helpers = %v, want %v", options, got, want)
+		}
+	}
+
+	if _, err := NewGenerator().generateCode(file(map[string]string{"helpers": "maybe"})); err == nil {
+		t.Errorf("generateCode() should fail with an invalid helpers option")
+	}
+}
+
+func TestGenerator_RenderPerPackage(t *testing.T) {
+	perPackage := config.C.PerPackage
+	defer func() { config.C.PerPackage = perPackage }()
+	config.C.PerPackage = true
+
+	dir := t.TempDir()
+	file := func(name string, constraint string, structNames ...string) *parser.File {
+		file := &parser.File{
+			Path:            parser.FilePath(filepath.Join(dir, name)),
+			Package:         "models",
+			BuildConstraint: constraint,
+		}
+		for _, structName := range structNames {
+			file.Structs = append(file.Structs, parser.Struct{
+				Name:    structName,
+				TagKeys: []string{"json"},
+				Fields:  []parser.Field{{Name: "ID", Tags: []tags.Tag{{Key: "json", Name: "id"}}}},
+			})
+		}
+		return file
+	}
+	files := map[parser.FilePath]*parser.File{
+		parser.FilePath(filepath.Join(dir, "user.go")):         file("user.go", "", "User", "Account"),
+		parser.FilePath(filepath.Join(dir, "post.go")):         file("post.go", "", "Post"),
+		parser.FilePath(filepath.Join(dir, "user_windows.go")): file("user_windows.go", "windows", "WindowsUser"),
+		parser.FilePath(filepath.Join(dir, "comment.go")):      nil,
+	}
+
+	g := NewGenerator()
+	outputs, err := g.Render(files)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("Render() returned %d files, want 2", len(outputs))
+	}
+
+	// The files without build constraint are aggregated in the package file
+	packageFile := outputs[0]
+	if want := filepath.Join(dir, "tagsvar_gen.go"); packageFile.Path != want {
+		t.Errorf("Render() path = %s, want %s", packageFile.Path, want)
+	}
+	code := string(packageFile.Code)
+	header := "// File: " + filepath.Join(dir, "post.go") + "\n// File: " + filepath.Join(dir, "user.go") + "\n"
+	if !strings.Contains(code, header) {
+		t.Errorf("Render() should list the source files:\n%s", code)
+	}
+	post, user, account := strings.Index(code, "JsonPostId"), strings.Index(code, "JsonUserId"), strings.Index(code, "JsonAccountId")
+	if post < 0 || user < post || account < user {
+		t.Errorf("Render() should order the structs by file and struct:\n%s", code)
+	}
+
+	// The files with a build constraint keep their own file
+	if want := filepath.Join(dir, "user_windows.vars.go"); outputs[1].Path != want {
+		t.Errorf("Render() path = %s, want %s", outputs[1].Path, want)
+	}
+	if !strings.Contains(string(outputs[1].Code), "//go:build windows") {
+		t.Errorf("Render() should keep the build constraint:\n%s", outputs[1].Code)
+	}
+
+	// The variables files of the sources are orphaned by the package file
+	generated := Header + "\n\npackage models\n"
+	existing := []string{
+		filepath.Join(dir, "tagsvar_gen.go"),
+		filepath.Join(dir, "user.vars.go"),
+		filepath.Join(dir, "user_windows.vars.go"),
+	}
+	for _, path := range existing {
+		if err := os.WriteFile(path, []byte(generated), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orphans, err := g.Orphans(files, existing)
+	if err != nil {
+		t.Fatalf("Orphans() error = %v", err)
+	}
+	if want := []string{filepath.Join(dir, "user.vars.go")}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("Orphans() = %v, want %v", orphans, want)
+	}
+
+	// The package file is orphaned without the option
+	config.C.PerPackage = false
+	orphans, err = g.Orphans(files, existing)
+	if err != nil {
+		t.Fatalf("Orphans() error = %v", err)
+	}
+	if want := []string{filepath.Join(dir, "tagsvar_gen.go")}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("Orphans() = %v, want %v", orphans, want)
+	}
+}
+
+func TestGenerator_RenderOutDir(t *testing.T) {
+	outDir, outPackage := config.C.OutDir, config.C.OutPackage
+	defer func() { config.C.OutDir, config.C.OutPackage = outDir, outPackage }()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "models", "user.go")
+	files := map[parser.FilePath]*parser.File{
+		parser.FilePath(source): {
+			Path:    parser.FilePath(source),
+			Package: "models",
+			Structs: []parser.Struct{{
+				Name:    "User",
+				TagKeys: []string{"json"},
+				Fields:  []parser.Field{{Name: "ID", Tags: []tags.Tag{{Key: "json", Name: "id"}}}},
+			}},
+		},
+	}
+
+	var tests = []struct {
+		name        string
+		outDir      string
+		outPackage  string
+		wantPath    string
+		wantPackage string
+		wantErr     bool
+	}{
+		{name: "same directory", wantPath: filepath.Join(dir, "models", "user.vars.go"), wantPackage: "package models"},
+		{name: "output directory", outDir: "fields", wantPath: filepath.Join(dir, "models", "fields", "user.vars.go"), wantPackage: "package fields"},
+		{name: "output package", outDir: filepath.Join("gen", "tag-names"), outPackage: "names", wantPath: filepath.Join(dir, "models", "gen", "tag-names", "user.vars.go"), wantPackage: "package names"},
+		{name: "invalid package name", outDir: "tag-names", wantErr: true},
+		{name: "parent directory", outDir: filepath.Join("..", "fields"), wantErr: true},
+		{name: "package without directory", outPackage: "fields", wantErr: true},
+	}
+	for _, test := range tests {
+		config.C.OutDir, config.C.OutPackage = test.outDir, test.outPackage
+
+		outputs, err := NewGenerator().Render(files)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: Render() error = %v, wantErr %v", test.name, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if outputs[0].Path != test.wantPath {
+			t.Errorf("%s: Render() path = %s, want %s", test.name, outputs[0].Path, test.wantPath)
+		}
+		if !strings.Contains(string(outputs[0].Code), test.wantPackage+"\n") {
+			t.Errorf("%s: Render() should contain %s:\n%s", test.name, test.wantPackage, outputs[0].Code)
+		}
+		if got := SourcePath(outputs[0].Path); got != source {
+			t.Errorf("%s: SourcePath() = %s, want %s", test.name, got, source)
+		}
+	}
+}
+
+func TestGenerator_RenderJobs(t *testing.T) {
+	defer func(jobs int) { config.C.Jobs = jobs }(config.C.Jobs)
+
+	dir := syntheticTree(t, 120)
+
+	var want []*Output
+	for _, jobs := range []int{1, 3, 16} {
+		config.C.Jobs = jobs
+		files, err := parser.NewParser().ParseDir(dir, true)
+		if err != nil {
+			t.Fatalf("ParseDir() with %d jobs error = %v", jobs, err)
+		}
+		outputs, err := NewGenerator().Render(files)
+		if err != nil {
+			t.Fatalf("Render() with %d jobs error = %v", jobs, err)
+		}
+		if want == nil {
+			want = outputs
+			continue
+		}
+		if !reflect.DeepEqual(outputs, want) {
+			t.Errorf("Render() with %d jobs differs from a sequential render", jobs)
+		}
+	}
+}
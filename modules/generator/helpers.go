@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"strconv"
+)
+
+// helperNouns are the nouns of the lookup functions of the tag keys naming columns
+// The other tag keys use Name (ie: AuthorJsonName)
+var helperNouns = map[string]string{
+	"gorm": "Column",
+	"db":   "Column",
+}
+
+// HelpersData is the data of the helpers of a tag of a struct
+type HelpersData struct {
+	// Tag is the tag key
+	Tag string
+	// Fields is the name of the list of the tag names (ie: AuthorJsonFields)
+	Fields string
+	// ByField is the name of the map from the field names to the tag names (ie: AuthorJsonByField)
+	ByField string
+	// ByName is the name of the map from the tag names to the field names (ie: AuthorJsonByName)
+	ByName string
+	// NameFunc is the name of the function returning the tag name of a field (ie: AuthorJsonName, AuthorGormColumn)
+	NameFunc string
+	// FieldFunc is the name of the function returning the field of a tag name (ie: AuthorJsonField)
+	FieldFunc string
+	// All are the constants of the tag names, one per field
+	All []ConstData
+	// Names are the constants of the tag names, the first field wins when several fields share a name
+	Names []ConstData
+}
+
+// structHelpers checks if the helpers are generated for the struct
+// The helpers option of the directive overrides the configuration
+func structHelpers(s parser.Struct) (bool, error) {
+	value, ok := s.Options["helpers"]
+	if !ok {
+		return config.C.Helpers, nil
+	}
+	helpers, err := strconv.ParseBool(value)
+	if err != nil {
+		err = fmt.Errorf("invalid helpers option %s of struct %s", value, s.Name)
+		if s.Pos.IsValid() {
+			err = fmt.Errorf("%s: %w", s.Pos, err)
+		}
+		return false, err
+	}
+	return helpers, nil
+}
+
+// newHelpersData builds the data of the helpers of a tag
+func newHelpersData(tagData TagData) *HelpersData {
+	noun, ok := helperNouns[tagData.Key]
+	if !ok {
+		noun = "Name"
+	}
+
+	helpers := &HelpersData{
+		Tag:       tagData.Key,
+		All:       tagData.Consts,
+		Fields:    tagData.Namespace + "Fields",
+		ByField:   tagData.Namespace + "ByField",
+		ByName:    tagData.Namespace + "ByName",
+		NameFunc:  tagData.Namespace + noun,
+		FieldFunc: tagData.Namespace + "Field",
+	}
+
+	names := make(map[string]bool)
+	for _, c := range tagData.Consts {
+		if !names[c.Value] {
+			names[c.Value] = true
+			helpers.Names = append(helpers.Names, c)
+		}
+	}
+	return helpers
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"github.com/stoewer/go-strcase"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// DefaultNaming is the default template of the identifiers (ie: JsonAuthorId)
+const DefaultNaming = "{{.Tag}}{{.Struct}}{{.Field}}"
+
+// initialisms is the list of the Go initialisms written in upper case, like golint and revive
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true,
+	"GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"LHS": true, "QPS": true, "RAM": true, "RHS": true, "RPC": true, "SLA": true, "SMTP": true,
+	"SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true,
+	"UID": true, "UUID": true, "URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true,
+	"XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// NameData is the data given to the naming template
+// Each part is already in upper camel case
+type NameData struct {
+	// Tag is the tag key (ie: Json)
+	Tag string
+	// Struct is the struct name (ie: Author)
+	Struct string
+	// Field is the field name or its alias (ie: Id)
+	Field string
+}
+
+// naming returns the naming template, parsed on the first use
+func (g *Generator) naming() (*template.Template, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.namingTmpl == nil {
+		naming := config.C.Naming
+		if naming == "" {
+			naming = DefaultNaming
+		}
+		t, err := template.New("naming").Option("missingkey=error").Parse(naming)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse naming template %s: %w", naming, err)
+		}
+		g.namingTmpl = t
+	}
+	return g.namingTmpl, nil
+}
+
+// identifier generates an identifier from the naming template
+func (g *Generator) identifier(tag string, structName string, field string) (string, error) {
+	t, err := g.naming()
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+	err = t.Execute(&buf, NameData{
+		Tag:    camelCase(tag),
+		Struct: camelCase(structName),
+		Field:  camelCase(field),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	name := buf.String()
+	if config.C.Unexported {
+		name = unexport(name)
+	}
+	return name, nil
+}
+
+// namespaceName returns the name of the struct value of a struct and a tag (ie: AuthorJson)
+func (g *Generator) namespaceName(s parser.Struct, tag string) string {
+	name := camelCase(s.Name) + camelCase(tag)
+	if config.C.Unexported {
+		name = unexport(name)
+	}
+	return name
+}
+
+// optionsTypeName returns the name of the struct type of the options of a tag (ie: GormOptions)
+func (g *Generator) optionsTypeName(tag string) string {
+	name := camelCase(tag) + "Options"
+	if config.C.Unexported {
+		name = unexport(name)
+	}
+	return name
+}
+
+// camelCase converts a name to upper camel case
+// With the initialisms of the configuration, the initialisms are written in upper case (ie: Id -> ID)
+func camelCase(name string) string {
+	if !config.C.Initialisms {
+		return strcase.UpperCamelCase(name)
+	}
+
+	words := strings.Split(strcase.SnakeCase(name), "_")
+	for i, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			words[i] = upper
+		} else {
+			words[i] = strcase.UpperCamelCase(word)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// unexport converts the first word of an identifier to lower case
+// A leading initialism is lowered entirely (ie: JSONUserID -> jsonUserID)
+func unexport(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		// The last upper case letter before a lower case letter starts the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
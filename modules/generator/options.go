@@ -0,0 +1,319 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/go-mods/tagsvar/modules/config"
+	"go/token"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Emission modes of the tag options
+const (
+	// OptionsMap generates a map[string]any of strings, the flag options are nil
+	OptionsMap = "map"
+	// OptionsTyped generates a map[string]any of typed values, the flag options are true
+	OptionsTyped = "typed"
+	// OptionsStruct generates a struct type per tag (ie: GormOptions{Type: "uuid", PrimaryKey: true})
+	OptionsStruct = "struct"
+)
+
+// Types of the option values
+const (
+	optionString = "string"
+	optionInt    = "int"
+	optionFloat  = "float64"
+	optionBool   = "bool"
+)
+
+// OptionsTypeData is a struct type holding the options of a tag in a package
+type OptionsTypeData struct {
+	// Name is the name of the type (ie: GormOptions)
+	Name string
+	// Tag is the tag key (ie: gorm)
+	Tag string
+	// Fields are the fields of the type, sorted by option key
+	Fields []OptionsFieldData
+}
+
+// OptionsFieldData is a field of an options struct type
+type OptionsFieldData struct {
+	// Name is the name of the field (ie: PrimaryKey)
+	Name string
+	// Key is the option key (ie: primary_key)
+	Key string
+	// Type is the Go type of the field
+	Type string
+}
+
+// optionsMode returns the emission mode of the options of the configuration
+func optionsMode() (string, error) {
+	switch config.C.OptionsMode {
+	case "":
+		return OptionsMap, nil
+	case OptionsMap, OptionsTyped, OptionsStruct:
+		return config.C.OptionsMode, nil
+	}
+	return "", fmt.Errorf("unknown options mode %s", config.C.OptionsMode)
+}
+
+// optionsSchema parses the schema of the configuration
+// The schema is a comma separated list of tag.option=type (ie: gorm.size=int,gorm.precision=int)
+func optionsSchema() (map[string]string, error) {
+	schema := make(map[string]string)
+	for _, entry := range strings.Split(config.C.OptionsSchema, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, goType, found := strings.Cut(entry, "=")
+		if !found || !strings.Contains(key, ".") {
+			return nil, fmt.Errorf("invalid options schema entry %s, want tag.option=type", entry)
+		}
+		goType = strings.TrimSpace(goType)
+		if goType == "float" {
+			goType = optionFloat
+		}
+		switch goType {
+		case optionString, optionInt, optionFloat, optionBool:
+		default:
+			return nil, fmt.Errorf("invalid type %s of the options schema entry %s, want string, int, float or bool", goType, entry)
+		}
+		schema[strings.TrimSpace(key)] = goType
+	}
+	return schema, nil
+}
+
+// inferOption returns the type of an option value
+// The flag options (without value) are booleans
+// The values which would not be written back as is (ie: 007, +1) are strings
+func inferOption(option OptionData) string {
+	if !option.HasValue {
+		return optionBool
+	}
+	if option.Value == "true" || option.Value == "false" {
+		return optionBool
+	}
+	if i, err := strconv.ParseInt(option.Value, 10, 64); err == nil {
+		if strconv.FormatInt(i, 10) == option.Value {
+			return optionInt
+		}
+		return optionString
+	}
+	if strings.ContainsAny(option.Value, "0123456789") && !strings.ContainsAny(option.Value, "xXpP_") {
+		if _, err := strconv.ParseFloat(option.Value, 64); err == nil {
+			return optionFloat
+		}
+	}
+	return optionString
+}
+
+// widenOption returns the type holding the values of both types
+func widenOption(a string, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case (a == optionInt && b == optionFloat) || (a == optionFloat && b == optionInt):
+		return optionFloat
+	}
+	return optionString
+}
+
+// optionMember returns the struct field of an option key (ie: primary_key -> PrimaryKey, min=3 -> Min3)
+// The characters which cannot be part of an identifier separate the words,
+// a key starting with a digit is prefixed by Option
+func optionMember(key string) (string, error) {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	member := camelCase(strings.Join(words, "_"))
+	if member != "" && unicode.IsDigit([]rune(member)[0]) {
+		member = "Option" + member
+	}
+	if !token.IsIdentifier(member) {
+		return "", fmt.Errorf("the option %q cannot be converted to a struct field", key)
+	}
+	return member, nil
+}
+
+// optionLiteral returns the Go literal of the option value for the type
+func optionLiteral(option OptionData, goType string) (string, error) {
+	value := option.Value
+	if !option.HasValue {
+		value = "true"
+	}
+
+	switch goType {
+	case optionBool:
+		if value != "true" && value != "false" {
+			return "", fmt.Errorf("option %s: %s is not a bool", option.Key, value)
+		}
+		return value, nil
+	case optionInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("option %s: %s is not an int", option.Key, value)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case optionFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("option %s: %s is not a float", option.Key, value)
+		}
+		// The literal must stay a float in a map[string]any
+		literal := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(literal, ".eE") {
+			literal += ".0"
+		}
+		return literal, nil
+	}
+	return strconv.Quote(value), nil
+}
+
+// resolveOptions computes the literals of the options of the files
+//
+//	map: the values are strings, the flag options are nil
+//	typed: the values are typed by the schema or by inference
+//	struct: the files generated in the same directory with the same package name share a struct type
+//	per tag, its fields are typed by the schema or by the inference of all the values of the package
+//
+// The struct types are declared by the first file of the package without build constraint
+func (g *Generator) resolveOptions(files []*FileData) error {
+	mode, err := optionsMode()
+	if err != nil {
+		return err
+	}
+	schema, err := optionsSchema()
+	if err != nil {
+		return err
+	}
+
+	// Types of the options by package, tag and key
+	types := make(map[string]map[string]map[string]string)
+	declaring := make(map[string]*FileData)
+	var packages []string
+
+	for _, file := range files {
+		pkg := filepath.Dir(OutputPath(file.Path)) + ":" + file.Package
+		if _, ok := types[pkg]; !ok {
+			types[pkg] = make(map[string]map[string]string)
+			packages = append(packages, pkg)
+		}
+		// The types are declared by a file without build constraint if possible
+		if declaring[pkg] == nil || (declaring[pkg].BuildConstraint != "" && file.BuildConstraint == "") {
+			declaring[pkg] = file
+		}
+
+		for i := range file.Structs {
+			for j := range file.Structs[i].Tags {
+				t := &file.Structs[i].Tags[j]
+				for k := range t.Vars {
+					v := &t.Vars[k]
+					for l := range v.Options {
+						o := &v.Options[l]
+
+						// Type of the option
+						goType, typed := schema[t.Key+"."+o.Key]
+						if !typed {
+							goType = inferOption(*o)
+						}
+						o.Type = goType
+
+						switch mode {
+						case OptionsMap:
+							o.Literal = "nil"
+							if o.HasValue {
+								o.Literal = strconv.Quote(o.Value)
+							}
+						case OptionsTyped:
+							o.Literal, err = optionLiteral(*o, goType)
+							if err != nil {
+								return fmt.Errorf("%s: %w", position(v.Field.Pos, file.Path), err)
+							}
+						case OptionsStruct:
+							o.Member, err = optionMember(o.Key)
+							if err != nil {
+								return fmt.Errorf("%s: %w", position(v.Field.Pos, file.Path), err)
+							}
+							if types[pkg][t.Key] == nil {
+								types[pkg][t.Key] = make(map[string]string)
+							}
+							types[pkg][t.Key][o.Key] = widenOption(types[pkg][t.Key][o.Key], goType)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if mode != OptionsStruct {
+		return nil
+	}
+
+	// Declare the struct types and type the literals
+	for _, pkg := range packages {
+		typeNames := make(map[string]string)
+		for _, tag := range sortedKeys(types[pkg]) {
+			optionsType := OptionsTypeData{Name: g.optionsTypeName(tag), Tag: tag}
+			members := make(map[string]string)
+			for _, key := range sortedKeys(types[pkg][tag]) {
+				// The keys were checked with the options
+				member, _ := optionMember(key)
+				if other, exists := members[member]; exists {
+					return fmt.Errorf("options %s and %s of the %s tag both generate the %s field", other, key, tag, member)
+				}
+				members[member] = key
+				optionsType.Fields = append(optionsType.Fields, OptionsFieldData{Name: member, Key: key, Type: types[pkg][tag][key]})
+			}
+			typeNames[tag] = optionsType.Name
+			declaring[pkg].OptionsTypes = append(declaring[pkg].OptionsTypes, optionsType)
+		}
+
+		for _, file := range files {
+			if filepath.Dir(OutputPath(file.Path))+":"+file.Package != pkg {
+				continue
+			}
+			for i := range file.Structs {
+				for j := range file.Structs[i].Tags {
+					t := &file.Structs[i].Tags[j]
+					for k := range t.Vars {
+						v := &t.Vars[k]
+						v.Type = typeNames[t.Key]
+						for l := range v.Options {
+							o := &v.Options[l]
+							o.Type = types[pkg][t.Key][o.Key]
+							o.Literal, err = optionLiteral(*o, o.Type)
+							if err != nil {
+								return fmt.Errorf("%s: %w", position(v.Field.Pos, file.Path), err)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// position returns the position in the source file, or the path of the file if the position is unknown
+func position(pos token.Position, path string) string {
+	if !pos.IsValid() {
+		return path
+	}
+	return pos.String()
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
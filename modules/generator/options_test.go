@@ -0,0 +1,163 @@
+package generator
+
+import (
+	"github.com/go-mods/tags"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/parser"
+	"strings"
+	"testing"
+)
+
+func TestInferOption(t *testing.T) {
+	var tests = []struct {
+		option OptionData
+		want   string
+	}{
+		{OptionData{Key: "primary_key"}, optionBool},
+		{OptionData{Key: "index", Value: "true", HasValue: true}, optionBool},
+		{OptionData{Key: "size", Value: "255", HasValue: true}, optionInt},
+		{OptionData{Key: "precision", Value: "2.5", HasValue: true}, optionFloat},
+		{OptionData{Key: "type", Value: "uuid", HasValue: true}, optionString},
+		{OptionData{Key: "default", Value: "Inf", HasValue: true}, optionString},
+		{OptionData{Key: "default", Value: "0x10", HasValue: true}, optionString},
+		{OptionData{Key: "code", Value: "007", HasValue: true}, optionString},
+		{OptionData{Key: "offset", Value: "+1", HasValue: true}, optionString},
+		{OptionData{Key: "offset", Value: "-1", HasValue: true}, optionInt},
+	}
+	for _, test := range tests {
+		if got := inferOption(test.option); got != test.want {
+			t.Errorf("inferOption(%v) = %s, want %s", test.option, got, test.want)
+		}
+	}
+}
+
+func TestGenerator_RenderOptions(t *testing.T) {
+	mode, schema := config.C.OptionsMode, config.C.OptionsSchema
+	defer func() { config.C.OptionsMode, config.C.OptionsSchema = mode, schema }()
+
+	file := func(path string, structName string, options ...*tags.Option) *parser.File {
+		return &parser.File{
+			Path:    parser.FilePath(path),
+			Package: "models",
+			Structs: []parser.Struct{{
+				Name:    structName,
+				TagKeys: []string{"gorm"},
+				Fields:  []parser.Field{{Name: "ID", Tags: []tags.Tag{{Key: "gorm", Name: "id", Options: options}}}},
+			}},
+		}
+	}
+	files := map[parser.FilePath]*parser.File{
+		"author.go": file("author.go", "Author", &tags.Option{Key: "size", Value: "255"}, &tags.Option{Key: "primary_key"}),
+		"blog.go":   file("blog.go", "Blog", &tags.Option{Key: "size", Value: "big"}, &tags.Option{Key: "code", Value: "007"}),
+	}
+
+	var tests = []struct {
+		name   string
+		mode   string
+		schema string
+		want   map[string][]string
+	}{
+		{
+			name: "map",
+			mode: OptionsMap,
+			want: map[string][]string{
+				"author.vars.go": {`"size":        "255"`, `"primary_key": nil`},
+				"blog.vars.go":   {`"size": "big"`, `"code": "007"`},
+			},
+		},
+		{
+			name: "typed",
+			mode: OptionsTyped,
+			want: map[string][]string{
+				"author.vars.go": {`"size":        255`, `"primary_key": true`},
+				"blog.vars.go":   {`"size": "big"`, `"code": "007"`},
+			},
+		},
+		{
+			name:   "schema",
+			mode:   OptionsTyped,
+			schema: "gorm.code=string",
+			want: map[string][]string{
+				"blog.vars.go": {`"code": "007"`},
+			},
+		},
+		{
+			name: "struct",
+			mode: OptionsStruct,
+			want: map[string][]string{
+				"author.vars.go": {"type GormOptions struct {\n\tCode       string // code\n\tPrimaryKey bool   // primary_key\n\tSize       string // size\n}", "GormAuthorIdOptions = GormOptions{\n\t\tSize:       \"255\",\n\t\tPrimaryKey: true,\n\t}"},
+				"blog.vars.go":   {"GormBlogIdOptions = GormOptions{\n\t\tSize: \"big\",\n\t\tCode: \"007\",\n\t}"},
+			},
+		},
+	}
+	for _, test := range tests {
+		config.C.OptionsMode, config.C.OptionsSchema = test.mode, test.schema
+
+		outputs, err := NewGenerator().Render(files)
+		if err != nil {
+			t.Errorf("%s: Render() error = %v", test.name, err)
+			continue
+		}
+		for _, output := range outputs {
+			for _, want := range test.want[output.Path] {
+				if !strings.Contains(string(output.Code), want) {
+					t.Errorf("%s: Render() %s should contain %s:\n%s", test.name, output.Path, want, output.Code)
+				}
+			}
+			if output.Path == "blog.vars.go" && strings.Contains(string(output.Code), "type GormOptions") {
+				t.Errorf("%s: Render() %s should not declare GormOptions:\n%s", test.name, output.Path, output.Code)
+			}
+		}
+	}
+
+	// The keys which are not identifiers generate valid fields
+	config.C.OptionsMode, config.C.OptionsSchema = OptionsStruct, ""
+	outputs, err := NewGenerator().Render(map[parser.FilePath]*parser.File{
+		"user.go": file("user.go", "User", &tags.Option{Key: "min=3"}, &tags.Option{Key: "not null"}),
+	})
+	if err != nil {
+		t.Errorf("Render() error = %v", err)
+	} else if code := string(outputs[0].Code); !strings.Contains(code, "Min3    bool // min=3") || !strings.Contains(code, "NotNull bool // not null") {
+		t.Errorf("Render() should generate the Min3 and NotNull fields:\n%s", code)
+	}
+	_, err = NewGenerator().Render(map[parser.FilePath]*parser.File{"user.go": file("user.go", "User", &tags.Option{Key: "=="})})
+	if err == nil || !strings.Contains(err.Error(), "user.go: the option \"==\"") {
+		t.Errorf("Render() error = %v, want a positioned option error", err)
+	}
+
+	config.C.OptionsMode, config.C.OptionsSchema = OptionsTyped, "gorm.size=int"
+	if _, err := NewGenerator().Render(files); err == nil || !strings.Contains(err.Error(), "big is not an int") {
+		t.Errorf("Render() error = %v, want a schema error", err)
+	}
+	config.C.OptionsMode, config.C.OptionsSchema = OptionsTyped, "size=int"
+	if _, err := NewGenerator().Render(files); err == nil {
+		t.Errorf("Render() should fail with an invalid schema")
+	}
+}
+
+func TestOptionMember(t *testing.T) {
+	var tests = []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "primary_key", want: "PrimaryKey"},
+		{key: "not null", want: "NotNull"},
+		{key: "min=3", want: "Min3"},
+		{key: "default:'x'", want: "DefaultX"},
+		{key: "3d", want: "Option3d"},
+		{key: "==", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := optionMember(test.key)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("optionMember(%q) should fail", test.key)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("optionMember(%q) = %s, %v, want %s", test.key, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/rs/zerolog/log"
+	"go/token"
+	"path/filepath"
+	"strconv"
+)
+
+// Symbol is an identifier declared by a generated file
+type Symbol struct {
+	// Name is the identifier
+	Name string
+	// Struct is the name of the struct
+	Struct string
+	// Field is the name of the field
+	Field string
+	// Pos is the position of the field in the source file
+	Pos token.Position
+}
+
+// String returns the position and the field of the symbol
+func (s Symbol) String() string {
+	name := s.Struct
+	if s.Field != "" {
+		name += "." + s.Field
+	}
+	if s.Pos.IsValid() {
+		return s.Pos.String() + " (" + name + ")"
+	}
+	return name
+}
+
+// Collision is an identifier declared twice in a package
+type Collision struct {
+	// First is the first declaration
+	First Symbol
+	// Second is the declaration colliding with the first one
+	Second Symbol
+}
+
+// Error returns the description of the collision with both positions
+func (c *Collision) Error() string {
+	return fmt.Sprintf("%s redeclared: %s and %s", c.First.Name, c.First, c.Second)
+}
+
+// symbolTable holds the identifiers declared in a package
+type symbolTable map[string]Symbol
+
+// resolveCollisions checks that the identifiers generated for the files are unique in each package
+// The files generated in the same directory with the same package name share a symbol table
+// The collisions are returned as errors, or renamed with a numeric suffix if disambiguation is enabled
+func (g *Generator) resolveCollisions(files []*FileData) error {
+	tables := make(map[string]symbolTable)
+	var errs []error
+
+	for _, file := range files {
+		key := filepath.Dir(OutputPath(file.Path)) + ":" + file.Package
+		table, ok := tables[key]
+		if !ok {
+			table = make(symbolTable)
+			tables[key] = table
+		}
+
+		for i := range file.OptionsTypes {
+			o := &file.OptionsTypes[i]
+			errs = append(errs, table.declare(&o.Name, Symbol{Struct: o.Name}))
+		}
+
+		for i := range file.Structs {
+			s := &file.Structs[i]
+			for j := range s.Tags {
+				t := &s.Tags[j]
+				if s.Flat {
+					errs = append(errs, declareFlat(table, s, t)...)
+				}
+				if s.Namespaced {
+					errs = append(errs, declareNamespaces(table, s, t)...)
+				}
+				if t.Helpers != nil {
+					errs = append(errs, declareHelpers(table, s, t)...)
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// declareFlat adds the constants and the variables of the tag to the table of the package
+func declareFlat(table symbolTable, s *StructData, t *TagData) []error {
+	var errs []error
+	for k := range t.Consts {
+		c := &t.Consts[k]
+		errs = append(errs, table.declare(&c.Name, Symbol{Struct: s.Name, Field: c.Field.Name, Pos: c.Field.Pos}))
+	}
+	for k := range t.Vars {
+		v := &t.Vars[k]
+		errs = append(errs, table.declare(&v.Name, Symbol{Struct: s.Name, Field: v.Field.Name, Pos: v.Field.Pos}))
+	}
+	return errs
+}
+
+// declareNamespaces adds the struct values of the tag to the table of the package
+// The fields of each struct value must be unique too
+func declareNamespaces(table symbolTable, s *StructData, t *TagData) []error {
+	var errs []error
+	if len(t.Consts) > 0 {
+		errs = append(errs, table.declare(&t.Namespace, Symbol{Struct: s.Name, Pos: s.Struct.Pos}))
+		members := make(symbolTable)
+		for k := range t.Consts {
+			c := &t.Consts[k]
+			errs = append(errs, members.declare(&c.Member, Symbol{Struct: s.Name, Field: c.Field.Name, Pos: c.Field.Pos}))
+		}
+	}
+	if len(t.Vars) > 0 {
+		errs = append(errs, table.declare(&t.OptionsNamespace, Symbol{Struct: s.Name, Pos: s.Struct.Pos}))
+		members := make(symbolTable)
+		for k := range t.Vars {
+			v := &t.Vars[k]
+			errs = append(errs, members.declare(&v.Member, Symbol{Struct: s.Name, Field: v.Field.Name, Pos: v.Field.Pos}))
+		}
+	}
+	return errs
+}
+
+// declare adds the identifier to the table
+// On a collision, the identifier is renamed if disambiguation is enabled, otherwise the collision is returned
+func (t symbolTable) declare(name *string, symbol Symbol) error {
+	symbol.Name = *name
+
+	first, exists := t[symbol.Name]
+	if !exists {
+		t[symbol.Name] = symbol
+		return nil
+	}
+
+	collision := &Collision{First: first, Second: symbol}
+	if !config.C.Disambiguate {
+		return collision
+	}
+
+	// Find the first free suffix
+	for i := 2; ; i++ {
+		renamed := symbol.Name + strconv.Itoa(i)
+		if _, exists := t[renamed]; !exists {
+			log.Warn().Msgf("%s, renamed to %s", collision.Error(), renamed)
+			symbol.Name = renamed
+			t[renamed] = symbol
+			*name = renamed
+			return nil
+		}
+	}
+}
+
+// declareHelpers adds the lists, the maps and the lookup functions of the tag to the table of the package
+func declareHelpers(table symbolTable, s *StructData, t *TagData) []error {
+	var errs []error
+	for _, name := range []*string{&t.Helpers.Fields, &t.Helpers.ByField, &t.Helpers.ByName, &t.Helpers.NameFunc, &t.Helpers.FieldFunc} {
+		errs = append(errs, table.declare(name, Symbol{Struct: s.Name, Pos: s.Struct.Pos}))
+	}
+	return errs
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/go-mods/tagsvar/modules/config"
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+// defaultTemplates are the templates used to generate the variables files
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// rootTemplate is the name of the template executed for each parsed file
+const rootTemplate = "file"
+
+// templateFuncs are the functions available to the templates
+// quote returns the Go string literal of a value
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+}
+
+// loadTemplates parses the default templates, then the template of the configuration
+// The template of the configuration can redefine any of the default templates with {{define "name"}}
+func loadTemplates() (*template.Template, error) {
+	t, err := template.New(rootTemplate).Funcs(templateFuncs).ParseFS(defaultTemplates, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	if config.C.Template != "" {
+		t, err = t.ParseFiles(filepath.Clean(config.C.Template))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse template %s: %w", config.C.Template, err)
+		}
+	}
+
+	return t, nil
+}
+
+// templates returns the templates, parsed on the first use
+func (g *Generator) templates() (*template.Template, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.tmpl == nil {
+		t, err := loadTemplates()
+		if err != nil {
+			return nil, err
+		}
+		g.tmpl = t
+	}
+	return g.tmpl, nil
+}
+
+// execute executes the root template with the data of the file
+func (g *Generator) execute(data *FileData) ([]byte, error) {
+	t, err := g.templates()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	if err := t.ExecuteTemplate(&buf, rootTemplate, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// syntheticTree writes a tree of source files in packages of 50 files, each file declaring two structs
+func syntheticTree(tb testing.TB, files int) string {
+	dir := tb.TempDir()
+	sources := make(map[string]string, files)
+	for i := 0; i < files; i++ {
+		pkg := fmt.Sprintf("pkg%03d", i/50)
+		src := strings.Builder{}
+		_, _ = fmt.Fprintf(&src, "package %s\n\n", pkg)
+		for j := 0; j < 2; j++ {
+			_, _ = fmt.Fprintf(&src, "// #tagsvar\ntype Model%d_%d struct {\n", i, j)
+			for k := 0; k < 10; k++ {
+				_, _ = fmt.Fprintf(&src, "\tField%d string `json:\"field_%d,omitempty\" db:\"field_%d\" gorm:\"column:field_%d;size:%d\"`\n", k, k, k, k, 16*(k+1))
+			}
+			src.WriteString("}\n\n")
+		}
+		sources[filepath.Join(pkg, fmt.Sprintf("model%04d.go", i))] = src.String()
+	}
+	writeFiles(tb, dir, sources)
+	return dir
+}
+
+// benchmarkJobs runs the benchmark sequentially and with GOMAXPROCS workers
+// The logs are disabled during the benchmark
+func benchmarkJobs(b *testing.B, run func(b *testing.B)) {
+	defer func(jobs int, logger zerolog.Logger) {
+		config.C.Jobs = jobs
+		log.Logger = logger
+	}(config.C.Jobs, log.Logger)
+	log.Logger = log.Logger.Level(zerolog.Disabled)
+
+	jobsList := []int{1}
+	if n := runtime.GOMAXPROCS(0); n > 1 {
+		jobsList = append(jobsList, n)
+	}
+	for _, jobs := range jobsList {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			config.C.Jobs = jobs
+			run(b)
+		})
+	}
+}
+
+func BenchmarkParser_ParseDir(b *testing.B) {
+	dir := syntheticTree(b, 1000)
+	benchmarkJobs(b, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := NewParser().ParseDir(dir, true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
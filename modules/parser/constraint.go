@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// knownOS is the list of the GOOS values used in the file name suffixes
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+	"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+	"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+	"windows": true, "zos": true,
+}
+
+// knownArch is the list of the GOARCH values used in the file name suffixes
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+	"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true,
+	"mips64le": true, "mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+	"ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true,
+	"sparc": true, "sparc64": true, "wasm": true,
+}
+
+// buildConstraint returns the build constraint of a file
+// It combines the //go:build line (or the // +build lines) with the constraint
+// implied by the file name suffixes, so that a generated file with another
+// name is built on the same platforms as its source
+func buildConstraint(filename string, astFile *ast.File) string {
+	var exprs []constraint.Expr
+
+	// Constraint written in the file, above the package clause
+	var plusBuild []constraint.Expr
+	for _, group := range astFile.Comments {
+		if group.Pos() >= astFile.Package {
+			break
+		}
+		for _, c := range group.List {
+			if constraint.IsGoBuild(c.Text) {
+				if expr, err := constraint.Parse(c.Text); err == nil {
+					exprs = append(exprs, expr)
+				}
+			} else if constraint.IsPlusBuild(c.Text) {
+				if expr, err := constraint.Parse(c.Text); err == nil {
+					plusBuild = append(plusBuild, expr)
+				}
+			}
+		}
+	}
+	// The // +build lines are only used without a //go:build line
+	if len(exprs) == 0 {
+		exprs = plusBuild
+	}
+
+	// Constraint implied by the file name
+	if expr := fileNameConstraint(filename); expr != nil {
+		exprs = append(exprs, expr)
+	}
+
+	if len(exprs) == 0 {
+		return ""
+	}
+	expr := exprs[0]
+	for _, x := range exprs[1:] {
+		expr = &constraint.AndExpr{X: expr, Y: x}
+	}
+	return expr.String()
+}
+
+// fileNameConstraint returns the constraint implied by the file name suffixes
+//
+//	name_GOOS.go, name_GOARCH.go, name_GOOS_GOARCH.go
+func fileNameConstraint(filename string) constraint.Expr {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	name = strings.TrimSuffix(name, "_test")
+
+	// The first element is never a suffix (ie: windows.go has no constraint)
+	parts := strings.Split(name, "_")
+	if len(parts) < 2 {
+		return nil
+	}
+	parts = parts[1:]
+
+	last := parts[len(parts)-1]
+	if len(parts) >= 2 && knownOS[parts[len(parts)-2]] && knownArch[last] {
+		return &constraint.AndExpr{
+			X: &constraint.TagExpr{Tag: parts[len(parts)-2]},
+			Y: &constraint.TagExpr{Tag: last},
+		}
+	}
+	if knownOS[last] || knownArch[last] {
+		return &constraint.TagExpr{Tag: last}
+	}
+	return nil
+}
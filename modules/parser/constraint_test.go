@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestBuildConstraint(t *testing.T) {
+	var tests = []struct {
+		filename string
+		content  string
+		want     string
+	}{
+		{filename: "user.go", content: "package testdata\n", want: ""},
+		{filename: "user.go", content: "//go:build exclude\n\npackage testdata\n", want: "exclude"},
+		{filename: "user.go", content: "// +build linux darwin\n\npackage testdata\n", want: "linux || darwin"},
+		{filename: "user.go", content: "//go:build !windows\n// +build !windows\n\npackage testdata\n", want: "!windows"},
+		{filename: "user_windows.go", content: "package testdata\n", want: "windows"},
+		{filename: "user_linux_arm64.go", content: "package testdata\n", want: "linux && arm64"},
+		{filename: "user_amd64_test.go", content: "package testdata\n", want: "amd64"},
+		{filename: "user_windows.go", content: "//go:build cgo || tools\n\npackage testdata\n", want: "(cgo || tools) && windows"},
+		{filename: "windows.go", content: "package testdata\n", want: ""},
+		{filename: "user_unknown.go", content: "package testdata\n", want: ""},
+		{filename: "user.go", content: "// Package testdata\n//go:build ignore\npackage testdata\n", want: "ignore"},
+		{filename: "user.go", content: "package testdata\n\n//go:build ignore\n", want: ""},
+	}
+
+	for _, test := range tests {
+		astFile, err := parser.ParseFile(token.NewFileSet(), test.filename, test.content, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		if got := buildConstraint(test.filename, astFile); got != test.want {
+			t.Errorf("buildConstraint(%q, %q) got = %q, want %q", test.filename, test.content, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/go-mods/tags"
+	"github.com/stoewer/go-strcase"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect is the semantics of a tag key for the library using it
+// It is used to generate the names the library actually uses at runtime
+type Dialect interface {
+	// Resolve sets the name of the tag used by the library for the field
+	// It returns false if the library ignores the field (ie: json:"-")
+	Resolve(tag *tags.Tag, field string) bool
+}
+
+// dialects is the registry of the dialects by tag key
+var dialects = struct {
+	sync.RWMutex
+	m map[string]Dialect
+}{m: map[string]Dialect{
+	"json":         nameDialect{defaultName: identity},
+	"xml":          xmlDialect{},
+	"yaml":         nameDialect{defaultName: strings.ToLower},
+	"bson":         nameDialect{defaultName: strings.ToLower},
+	"db":           nameDialect{defaultName: strings.ToLower},
+	"toml":         nameDialect{defaultName: identity},
+	"mapstructure": nameDialect{defaultName: identity},
+	"gorm":         gormDialect{},
+}}
+
+// RegisterDialect registers the dialect of a tag key
+// An existing dialect of the key is replaced
+func RegisterDialect(key string, dialect Dialect) {
+	dialects.Lock()
+	defer dialects.Unlock()
+	dialects.m[key] = dialect
+}
+
+// LookupDialect returns the dialect of a tag key
+func LookupDialect(key string) (Dialect, bool) {
+	dialects.RLock()
+	defer dialects.RUnlock()
+	dialect, ok := dialects.m[key]
+	return dialect, ok
+}
+
+// resolveDialects applies the dialects to the tags of a field
+// The tags ignored by their library are removed, as are all the tags of an unexported field
+// The tags without dialect are kept as written
+func resolveDialects(tagList []tags.Tag, field string) []tags.Tag {
+	resolved := make([]tags.Tag, 0, len(tagList))
+	for _, t := range tagList {
+		dialect, ok := LookupDialect(t.Key)
+		if !ok {
+			resolved = append(resolved, t)
+			continue
+		}
+		if !isExported(field) {
+			continue
+		}
+		t.Options = append([]*tags.Option(nil), t.Options...)
+		if dialect.Resolve(&t, field) {
+			resolved = append(resolved, t)
+		}
+	}
+	return resolved
+}
+
+// isExported checks if the field is exported
+func isExported(field string) bool {
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsUpper(r)
+}
+
+// identity returns the field name unchanged
+func identity(field string) string {
+	return field
+}
+
+// nameDialect is the dialect of the encoding libraries (encoding/json, yaml, bson, sqlx, ...)
+//
+//	json:"-" -> ignored
+//	json:"-," -> the name is -
+//	json:",omitempty" -> the name is the default name of the field
+type nameDialect struct {
+	// defaultName returns the name used when the tag has no name
+	defaultName func(field string) string
+}
+
+// Resolve sets the name of the tag, or the default name of the field
+func (d nameDialect) Resolve(t *tags.Tag, field string) bool {
+	if t.Name == "" && len(t.Options) > 0 {
+		switch t.Options[0].Key {
+		case "-":
+			return false
+		case "-,":
+			t.Name = "-"
+			t.Options = t.Options[1:]
+			return true
+		}
+	}
+	if t.Name == "-" {
+		return false
+	}
+	if t.Name == "" {
+		t.Name = d.defaultName(field)
+	}
+	return true
+}
+
+// xmlDialect is the dialect of encoding/xml
+// The fields mapped to the character data, the inner XML or a comment have no name
+type xmlDialect struct{}
+
+// Resolve sets the name of the tag, or the name of the field
+func (d xmlDialect) Resolve(t *tags.Tag, field string) bool {
+	if t.Name == "" {
+		for _, o := range t.Options {
+			switch o.Key {
+			case "chardata", "cdata", "innerxml", "comment":
+				return true
+			}
+		}
+	}
+	return nameDialect{defaultName: identity}.Resolve(t, field)
+}
+
+// gormDialect is the dialect of gorm
+//
+//	gorm:"-", gorm:"-:all" -> ignored
+//	gorm:"column:user_id" -> the name is user_id
+//	gorm:"type:int" -> the name is the snake case of the field name (ie: UserID -> user_id)
+//	gorm:"embedded", gorm:"foreignKey:UserID" -> ignored, the field is not a column
+//
+// The gorm settings are all key:value pairs or flags, so a setting read as the
+// name of the tag (ie: gorm:"primaryKey") is moved to the options
+type gormDialect struct{}
+
+// Resolve sets the column name of the field
+func (d gormDialect) Resolve(t *tags.Tag, field string) bool {
+	if t.Name == "-" {
+		return false
+	}
+	for _, o := range t.Options {
+		if o.Value == nil && (o.Key == "-" || o.Key == "-:all") {
+			return false
+		}
+	}
+	if !gormColumn(t) {
+		return false
+	}
+
+	if t.Name != "" {
+		t.Options = append([]*tags.Option{{Key: t.Name}}, t.Options...)
+	}
+	t.Name = strcase.SnakeCase(field)
+	if o := t.GetOption("column"); o != nil && o.Value != nil {
+		t.Name = strings.TrimSpace(fmt.Sprint(o.Value))
+	}
+	return true
+}
+
+// gormNoColumn are the gorm settings of the fields which are not a column
+// The fields of an embedded struct are columns, the embedded field is not
+// The association fields are stored in the table of the associated struct or in a join table
+var gormNoColumn = []string{
+	"embedded",
+	"foreignKey",
+	"references",
+	"polymorphic",
+	"polymorphicValue",
+	"many2many",
+	"joinForeignKey",
+	"joinReferences",
+}
+
+// gormColumn checks if the field of the gorm tag is a column
+// The gorm settings are case insensitive
+func gormColumn(t *tags.Tag) bool {
+	keys := make([]string, 0, len(t.Options)+1)
+	if t.Name != "" {
+		keys = append(keys, t.Name)
+	}
+	for _, o := range t.Options {
+		keys = append(keys, o.Key)
+	}
+	for _, key := range keys {
+		for _, setting := range gormNoColumn {
+			if strings.EqualFold(strings.TrimSpace(key), setting) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"github.com/go-mods/tags"
+	"strings"
+	"testing"
+)
+
+func TestResolveDialects(t *testing.T) {
+	var tests = []struct {
+		tag   string
+		field string
+		want  string
+		ok    bool
+	}{
+		{tag: `json:"id"`, field: "ID", want: "id", ok: true},
+		{tag: `json:"-"`, field: "Password", ok: false},
+		{tag: `json:"-,"`, field: "Dash", want: "-", ok: true},
+		{tag: `json:",omitempty"`, field: "Email", want: "Email", ok: true},
+		{tag: `json:"id"`, field: "id", ok: false},
+		{tag: `yaml:",omitempty"`, field: "UserName", want: "username", ok: true},
+		{tag: `bson:",omitempty"`, field: "UserName", want: "username", ok: true},
+		{tag: `db:",omitempty"`, field: "UserName", want: "username", ok: true},
+		{tag: `xml:",attr"`, field: "Lang", want: "Lang", ok: true},
+		{tag: `xml:",chardata"`, field: "Text", want: "", ok: true},
+		{tag: `gorm:"column:user_id;type:int"`, field: "Owner", want: "user_id", ok: true},
+		{tag: `gorm:"type:int"`, field: "UserID", want: "user_id", ok: true},
+		{tag: `gorm:"primaryKey"`, field: "ID", want: "id", ok: true},
+		{tag: `gorm:"-"`, field: "Cache", ok: false},
+		{tag: `gorm:"-:all"`, field: "Cache", ok: false},
+		{tag: `gorm:"embedded"`, field: "Author", ok: false},
+		{tag: `gorm:"embedded;embeddedPrefix:author_"`, field: "Author", ok: false},
+		{tag: `gorm:"foreignKey:UserID"`, field: "Orders", ok: false},
+		{tag: `gorm:"many2many:user_languages"`, field: "Languages", ok: false},
+		{tag: `gorm:"references:ID;constraint:OnDelete:CASCADE"`, field: "Company", ok: false},
+		{tag: `gorm:"foreignkey:UserID"`, field: "Orders", ok: false},
+		{tag: `custom:",omitempty"`, field: "Name", want: "", ok: true},
+	}
+
+	for _, test := range tests {
+		tagList, err := tags.Parse(test.tag)
+		if err != nil {
+			t.Fatalf("tags.Parse(%s) error = %v", test.tag, err)
+		}
+		parsed := make([]tags.Tag, 0, len(tagList))
+		for _, tag := range tagList {
+			parsed = append(parsed, *tag)
+		}
+
+		resolved := resolveDialects(parsed, test.field)
+		if !test.ok {
+			if len(resolved) != 0 {
+				t.Errorf("resolveDialects(%s, %s) got = %v, want the tag to be ignored", test.tag, test.field, resolved)
+			}
+			continue
+		}
+		if len(resolved) != 1 || resolved[0].Name != test.want {
+			t.Errorf("resolveDialects(%s, %s) got = %v, want %s", test.tag, test.field, resolved, test.want)
+		}
+	}
+}
+
+func TestResolveDialectsGormOptions(t *testing.T) {
+	tagList, _ := tags.Parse(`gorm:"primaryKey;type:uuid"`)
+	resolved := resolveDialects([]tags.Tag{*tagList[0]}, "ID")
+	if len(resolved) != 1 || resolved[0].GetOption("primaryKey") == nil || resolved[0].GetOption("type") == nil {
+		t.Errorf("resolveDialects() got = %+v, want the primaryKey and type options", resolved)
+	}
+	// The parsed tag is not modified
+	if tagList[0].Name != "primaryKey" || len(tagList[0].Options) != 1 {
+		t.Errorf("resolveDialects() modified the parsed tag %+v", tagList[0])
+	}
+}
+
+// upperDialect is a dialect using the upper case field name
+type upperDialect struct{}
+
+func (upperDialect) Resolve(t *tags.Tag, field string) bool {
+	t.Name = strings.ToUpper(field)
+	return true
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect("upper", upperDialect{})
+	defer func() {
+		dialects.Lock()
+		delete(dialects.m, "upper")
+		dialects.Unlock()
+	}()
+
+	if _, ok := LookupDialect("upper"); !ok {
+		t.Fatalf("LookupDialect() should find the registered dialect")
+	}
+	resolved := resolveDialects([]tags.Tag{{Key: "upper"}}, "Name")
+	if len(resolved) != 1 || resolved[0].Name != "NAME" {
+		t.Errorf("resolveDialects() got = %v, want NAME", resolved)
+	}
+}
@@ -0,0 +1,331 @@
+package parser
+
+import (
+	"github.com/go-mods/tags"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/rs/zerolog/log"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// scope is the location of a declaration
+// It is used to resolve the types used by the declaration
+//
+// The type information is only available with the packages backend
+type scope struct {
+	dir  string
+	file *ast.File
+	fset *token.FileSet
+	pkg  *types.Package
+	info *types.Info
+}
+
+// position returns the position in the source file
+// The position is empty if the file set is unknown
+func (s scope) position(pos token.Pos) token.Position {
+	if s.fset == nil {
+		return token.Position{}
+	}
+	return s.fset.Position(pos)
+}
+
+// declaration is a struct type declared in a package
+type declaration struct {
+	spec  *ast.TypeSpec
+	scope scope
+}
+
+// parseEmbedded flattens an embedded field
+// It returns the fields promoted from the embedded struct, preceded by the
+// embedded field itself for the tags where it has a name (ie: json:"base")
+// An embedded type which cannot be resolved to a struct is kept as a field named after its type
+func (p *Parser) parseEmbedded(field *ast.Field, comment string, preprocessor *Preprocessor, s scope, visiting map[*ast.StructType]bool) ([]Field, error) {
+	// Resolve the embedded struct
+	decl, err := p.resolveStruct(field.Type, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The embedded type cannot be flattened, it is kept as a field named after its type
+	if decl == nil {
+		log.Warn().Msgf("%s: could not resolve the struct embedded as %s, the field is not flattened", s.position(field.Type.Pos()), p.typeString(field.Type, s))
+		name := embeddedName(field.Type)
+		parsedField := Field{
+			Name:    name,
+			Comment: comment,
+			Type:    p.typeString(field.Type, s),
+			Tags:    p.parseTags(field.Tag, preprocessor),
+			Exclude: preprocessor.Exclude,
+			Pos:     s.position(field.Type.Pos()),
+		}
+		if p.dialects {
+			parsedField.Tags = resolveDialects(parsedField.Tags, name)
+		}
+		parsedField.Alias, _ = preprocessor.GetOption("name")
+		return []Field{parsedField}, nil
+	}
+	structType := decl.spec.Type.(*ast.StructType)
+
+	// Avoid infinite recursion on cyclic embedding
+	if visiting[structType] {
+		return nil, nil
+	}
+	visiting[structType] = true
+	defer delete(visiting, structType)
+
+	// Tags of the embedded field, used to know how the fields are promoted
+	embeddedTags := p.parseTags(field.Tag, preprocessor)
+
+	fields := make([]Field, 0)
+
+	// The embedded field is kept for the tags where it has a name
+	embeddedField := Field{
+		Name:    decl.spec.Name.Name,
+		Comment: comment,
+		Type:    p.typeString(field.Type, s),
+		Exclude: preprocessor.Exclude,
+		Pos:     s.position(field.Type.Pos()),
+	}
+	embeddedField.Alias, _ = preprocessor.GetOption("name")
+	for _, t := range embeddedTags {
+		if _, promoted := embedding(&t); !promoted && t.Name != "" && t.Name != "-" {
+			embeddedField.Tags = append(embeddedField.Tags, t)
+		}
+	}
+	if len(embeddedField.Tags) > 0 {
+		fields = append(fields, embeddedField)
+	}
+
+	// Parse the fields of the embedded struct
+	innerFields, err := p.parseFields(structType, preprocessor, decl.scope, visiting)
+	if err != nil {
+		return nil, err
+	}
+
+	// Promote the fields
+	for _, f := range innerFields {
+		f.Exclude = f.Exclude || preprocessor.Exclude
+		promotedTags := make([]tags.Tag, 0, len(f.Tags))
+		for _, t := range f.Tags {
+			prefix, promoted := embedding(findTag(embeddedTags, t.Key))
+			if !promoted {
+				continue
+			}
+			if t.Name != "" {
+				t.Name = prefix + t.Name
+			}
+			promotedTags = append(promotedTags, t)
+		}
+		f.Tags = promotedTags
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// embedding returns how the fields of an embedded struct are promoted for a tag
+// of the embedded field, and the prefix to add to their names
+//
+//	no tag, json:",omitempty" -> promoted
+//	json:",inline", yaml:",inline", bson:",inline" -> promoted
+//	gorm:"embedded;embeddedPrefix:author_" -> promoted with the author_ prefix
+//	json:"base" -> not promoted, the embedded field is a named field
+//	json:"-" -> not promoted
+func embedding(t *tags.Tag) (prefix string, promoted bool) {
+	if t == nil {
+		return "", true
+	}
+	if t.Name == "-" || t.HasOption("-") {
+		return "", false
+	}
+	if t.Name == "embedded" || t.HasOption("embedded") {
+		if o := t.GetOption("embeddedPrefix"); o != nil && o.Value != nil {
+			prefix, _ = o.Value.(string)
+		}
+		return prefix, true
+	}
+	if t.HasOption("inline") || t.Name == "" {
+		return "", true
+	}
+	return "", false
+}
+
+// embeddedName returns the name of an embedded field, the name of its type
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.IndexExpr:
+		return embeddedName(expr.X)
+	case *ast.IndexListExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// findTag returns the tag with the key or nil
+func findTag(tagsSlice []tags.Tag, key string) *tags.Tag {
+	for i := range tagsSlice {
+		if tagsSlice[i].Key == key {
+			return &tagsSlice[i]
+		}
+	}
+	return nil
+}
+
+// resolveStruct returns the declaration of the struct type used by the expression
+// It returns nil if the type cannot be resolved or is not a struct
+func (p *Parser) resolveStruct(expr ast.Expr, s scope) (*declaration, error) {
+	// Use the type information when available
+	if s.info != nil {
+		return p.resolveTypedStruct(expr, s)
+	}
+
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return p.resolveStruct(expr.X, s)
+	case *ast.IndexExpr:
+		return p.resolveStruct(expr.X, s)
+	case *ast.IndexListExpr:
+		return p.resolveStruct(expr.X, s)
+	case *ast.Ident:
+		return p.lookupDeclaration(s.dir, expr.Name)
+	case *ast.SelectorExpr:
+		pkg, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return nil, nil
+		}
+		dir := p.importDir(s, pkg.Name)
+		if dir == "" {
+			log.Debug().Msgf("Could not resolve the package %s used in %s", pkg.Name, s.dir)
+			return nil, nil
+		}
+		return p.lookupDeclaration(dir, expr.Sel.Name)
+	}
+	return nil, nil
+}
+
+// importDir returns the directory of the package imported under the name in the scope
+// It returns an empty string if the package cannot be found
+func (p *Parser) importDir(s scope, name string) string {
+	for _, spec := range s.file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil && spec.Name.Name != name {
+			continue
+		}
+		pkg := p.importPackage(path, s.dir)
+		if pkg == nil {
+			continue
+		}
+		if spec.Name != nil || pkg.Name == name {
+			return pkg.Dir
+		}
+	}
+	return ""
+}
+
+// importPackage finds the imported package from the source directory
+// It respects the go.mod of the source directory
+func (p *Parser) importPackage(path string, srcDir string) *build.Package {
+	key := srcDir + "|" + path
+	p.mu.Lock()
+	pkg, ok := p.imports[key]
+	p.mu.Unlock()
+	if ok {
+		return pkg
+	}
+
+	// The go command is run from the source directory to find its go.mod
+	ctxt := build.Default
+	ctxt.Dir = srcDir
+	pkg, err := ctxt.Import(path, srcDir, 0)
+	if err != nil {
+		log.Debug().Err(err).Msgf("Could not import the package %s", path)
+		pkg = nil
+	}
+
+	p.mu.Lock()
+	p.imports[key] = pkg
+	p.mu.Unlock()
+	return pkg
+}
+
+// lookupDeclaration returns the declaration of the struct type in the package directory
+func (p *Parser) lookupDeclaration(dir string, name string) (*declaration, error) {
+	p.mu.Lock()
+	declarations, ok := p.declarations[dir]
+	p.mu.Unlock()
+	if !ok {
+		var err error
+		declarations, err = p.parseDeclarations(dir)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.declarations[dir] = declarations
+		p.mu.Unlock()
+	}
+	return declarations[name], nil
+}
+
+// parseDeclarations parses the struct types declared in the package directory
+func (p *Parser) parseDeclarations(dir string) (map[string]*declaration, error) {
+	declarations := make(map[string]*declaration)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		filename := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !fs.IsBuildFile(filename) {
+			continue
+		}
+
+		fileSet := token.NewFileSet()
+		astFile, err := parser.ParseFile(fileSet, filename, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		collectDeclarations(declarations, scope{dir: dir, file: astFile, fset: fileSet})
+	}
+
+	return declarations, nil
+}
+
+// collectDeclarations adds the struct types declared in the file of the scope
+func collectDeclarations(declarations map[string]*declaration, s scope) {
+	for _, d := range s.file.Decls {
+		genDecl, ok := d.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if _, ok := typeSpec.Type.(*ast.StructType); ok {
+				declarations[typeSpec.Name.Name] = &declaration{
+					spec:  typeSpec,
+					scope: s,
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/rs/zerolog/log"
+	"go/ast"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// BackendAST parses the files one by one with go/parser, without type information
+	BackendAST = "ast"
+
+	// BackendPackages loads the packages with golang.org/x/tools/go/packages
+	// The types are checked, the build tags and the go.mod are respected
+	BackendPackages = "packages"
+)
+
+// loadMode is the information loaded by the packages backend
+// The dependencies are type-checked from source, so that the backend does not
+// depend on the export data format of the installed go toolchain
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedDeps |
+	packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
+
+// loadFile loads the package of a file with the packages backend
+// and returns the parsed File
+func (p *Parser) loadFile(filename string) (*File, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := p.loadPackages(filepath.Dir(filename), "file="+filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the file in the loaded packages
+	for _, pkg := range pkgs {
+		for i, astFile := range pkg.Syntax {
+			if pkg.CompiledGoFiles[i] == filename {
+				return p.parseAST(filename, astFile, p.packageScope(pkg, filename, astFile))
+			}
+		}
+	}
+	return nil, nil
+}
+
+// loadFiles loads the packages of the files with the packages backend
+// and returns a map of parsed File
+// The package of each directory is loaded once
+func (p *Parser) loadFiles(filenames []string) (map[FilePath]*File, error) {
+	// Group the files by directory
+	dirs := make(map[string]map[string]string)
+	order := make([]string, 0)
+	for _, filename := range filenames {
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			return nil, err
+		}
+		dir := filepath.Dir(abs)
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = make(map[string]string)
+			order = append(order, dir)
+		}
+		dirs[dir][abs] = filename
+	}
+
+	// Slice of parsed files
+	parsedFiles := make(map[FilePath]*File)
+
+	for _, dir := range order {
+		pkgs, err := p.loadPackages(dir, ".")
+		if err != nil {
+			return nil, err
+		}
+
+		// The requested files that are not loaded do not yield any struct
+		for _, filename := range dirs[dir] {
+			parsedFiles[FilePath(filename)] = nil
+		}
+
+		// Parse the requested files of the packages
+		for _, pkg := range pkgs {
+			for i, astFile := range pkg.Syntax {
+				filename, requested := dirs[dir][pkg.CompiledGoFiles[i]]
+				if !requested {
+					continue
+				}
+				parsedFile, err := p.parseAST(filename, astFile, p.packageScope(pkg, filename, astFile))
+				if err != nil {
+					return nil, err
+				}
+				parsedFiles[FilePath(filename)] = parsedFile
+			}
+		}
+	}
+	return parsedFiles, nil
+}
+
+// loadPackages loads the packages matching the patterns from the directory
+// The struct types of the loaded packages are added to the declarations
+func (p *Parser) loadPackages(dir string, patterns ...string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: loadMode,
+		Dir:  dir,
+		Fset: p.fset,
+	}
+
+	// Select the files with the build tags, GOOS and GOARCH of the configuration
+	if tags := fs.BuildTags(); len(tags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(tags, ",")}
+	}
+	if config.C.GOOS != "" || config.C.GOARCH != "" {
+		ctxt := fs.BuildContext()
+		cfg.Env = append(os.Environ(), "GOOS="+ctxt.GOOS, "GOARCH="+ctxt.GOARCH)
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		// The errors are reported but do not stop the generation,
+		// the type information is then incomplete
+		for _, pkgErr := range pkg.Errors {
+			log.Warn().Msgf("Package %s: %s", pkg.PkgPath, pkgErr.Error())
+		}
+	}
+
+	// Register the declarations of the packages and their dependencies with their type information
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for i, astFile := range pkg.Syntax {
+			filename := pkg.CompiledGoFiles[i]
+			pkgDir := filepath.Dir(filename)
+			if _, ok := p.declarations[pkgDir]; !ok {
+				p.declarations[pkgDir] = make(map[string]*declaration)
+			}
+			collectDeclarations(p.declarations[pkgDir], p.packageScope(pkg, filename, astFile))
+		}
+	})
+
+	return pkgs, nil
+}
+
+// packageScope returns the scope of a file loaded with the packages backend
+func (p *Parser) packageScope(pkg *packages.Package, filename string, astFile *ast.File) scope {
+	return scope{
+		dir:  filepath.Dir(filename),
+		file: astFile,
+		fset: pkg.Fset,
+		pkg:  pkg.Types,
+		info: pkg.TypesInfo,
+	}
+}
+
+// typeString returns the type of the expression
+// The type information is used when available, otherwise the type is read from the AST
+func (p *Parser) typeString(expr ast.Expr, s scope) string {
+	if s.info != nil {
+		if t := s.info.TypeOf(expr); t != nil {
+			return types.TypeString(t, func(other *types.Package) string {
+				if other == s.pkg {
+					return ""
+				}
+				return other.Name()
+			})
+		}
+	}
+	return p.parseType(expr)
+}
+
+// resolveTypedStruct returns the declaration of the struct type used by the expression
+// The type information is used to find the package where the type is declared
+func (p *Parser) resolveTypedStruct(expr ast.Expr, s scope) (*declaration, error) {
+	t := s.info.TypeOf(expr)
+	if pointer, ok := t.(*types.Pointer); ok {
+		t = pointer.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil, nil
+	}
+
+	// Find the directory of the declaration
+	obj := named.Origin().Obj()
+	position := s.fset.Position(obj.Pos())
+	if obj.Pkg() == nil || !position.IsValid() || position.Filename == "" {
+		return nil, nil
+	}
+
+	return p.lookupDeclaration(filepath.Dir(position.Filename), obj.Name())
+}
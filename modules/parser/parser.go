@@ -1,14 +1,19 @@
 package parser
 
 import (
+	"fmt"
 	"github.com/go-mods/tags"
+	"github.com/go-mods/tagsvar/modules/config"
 	"github.com/go-mods/tagsvar/modules/fs"
+	"github.com/go-mods/tagsvar/modules/workers"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type Parser struct {
@@ -17,41 +22,77 @@ type Parser struct {
 	// It is used by the parser to know if a struct should
 	// be included or not and to know which tags to include
 	//
+	// It holds the default state inherited by every declaration.
+	// Each declaration is evaluated against its own copy, so a
+	// directive never leaks from one struct to the next
+	//
 	// The default value is #tagsvar
 	preprocessor *Preprocessor
 
 	// This is used to indicate if the file should be processed or not even if the preprocessor is not found
 	forceProcess bool
+
+	// packages caches the package level defaults by directory
+	// They are read from the package comment of the doc.go file
+	packages map[string]*Preprocessor
+
+	// This is used to flatten the embedded structs
+	// The promoted fields are added to the embedding struct
+	flatten bool
+
+	// This is used to apply the semantics of the well-known tag keys (json, gorm, ...)
+	// so that the names match the ones used by the libraries at runtime
+	dialects bool
+
+	// declarations caches the struct types declared in a package directory
+	// They are used to resolve the embedded structs
+	declarations map[string]map[string]*declaration
+
+	// imports caches the imported packages by source directory and import path
+	imports map[string]*build.Package
+
+	// This is used to load the packages with full type information
+	// using golang.org/x/tools/go/packages instead of go/parser
+	typed bool
+
+	// fset is the file set shared by the packages loaded with type information
+	fset *token.FileSet
+
+	// jobs is the number of files parsed concurrently
+	jobs int
+
+	// mu guards the caches (packages, declarations and imports) when the files are parsed concurrently
+	mu sync.Mutex
 }
 
 // NewParser creates a new Parser
 func NewParser() *Parser {
 	return &Parser{
 		preprocessor: NewPreprocessor(),
+		packages:     make(map[string]*Preprocessor),
+		flatten:      config.C.Flatten,
+		dialects:     config.C.Dialects,
+		declarations: make(map[string]map[string]*declaration),
+		imports:      make(map[string]*build.Package),
+		typed:        config.C.Backend == BackendPackages,
+		fset:         token.NewFileSet(),
+		jobs:         workers.Jobs(),
 	}
 }
 
 // ParseDir parses a directory and returns a map of parsed File
 // It extracts the package name, the structs, the fields and the tags from the files
 // It will be used to generate the variables files
+// The files are listed with the rules of fs.ListFiles, whatever the backend
 func (p *Parser) ParseDir(path string, recursive bool) (map[FilePath]*File, error) {
 	// List files to parse
-	files, err := fs.ListFiles(path, recursive, fs.IsProjectFile)
+	files, err := fs.ListFiles(path, recursive, fs.IsBuildFile)
 	if err != nil {
 		return nil, err
 	}
-	// Slice of parsed files
-	parsedFiles := make(map[FilePath]*File)
 
 	// Parse the files
-	for _, filename := range files {
-		parsedFile, err := p.ParseFile(filename)
-		if err != nil {
-			return nil, err
-		}
-		parsedFiles[FilePath(filename)] = parsedFile
-	}
-	return parsedFiles, nil
+	return p.ParseFiles(files)
 }
 
 // ParseFile parses a file and returns a File
@@ -60,6 +101,11 @@ func (p *Parser) ParseDir(path string, recursive bool) (map[FilePath]*File, erro
 func (p *Parser) ParseFile(filename string) (*File, error) {
 	var err error
 
+	// Load the package of the file with the type information
+	if p.typed {
+		return p.loadFile(filename)
+	}
+
 	// Read the file
 	content, err := os.ReadFile(filepath.Clean(filename))
 	if err != nil {
@@ -74,6 +120,34 @@ func (p *Parser) ParseFile(filename string) (*File, error) {
 	return parsedFile, nil
 }
 
+// ParseFiles parses a list of files and returns a map of parsed File
+// The files are parsed concurrently, the error of the first file in the list is returned
+// With the packages backend, the package of each directory is loaded once
+func (p *Parser) ParseFiles(filenames []string) (map[FilePath]*File, error) {
+	// Load the packages with the type information
+	if p.typed {
+		return p.loadFiles(filenames)
+	}
+
+	// Parse the files
+	files := make([]*File, len(filenames))
+	err := workers.Run(len(filenames), p.jobs, func(i int) error {
+		var err error
+		files[i], err = p.ParseFile(filenames[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Slice of parsed files
+	parsedFiles := make(map[FilePath]*File, len(filenames))
+	for i, filename := range filenames {
+		parsedFiles[FilePath(filename)] = files[i]
+	}
+	return parsedFiles, nil
+}
+
 func (p *Parser) parseFile(filename string, content []byte) (*File, error) {
 	// Parse the file and get the AST
 	fileSet := token.NewFileSet()
@@ -82,10 +156,27 @@ func (p *Parser) parseFile(filename string, content []byte) (*File, error) {
 		return nil, err
 	}
 
+	return p.parseAST(filename, astFile, scope{dir: filepath.Dir(filename), file: astFile, fset: fileSet})
+}
+
+// parseAST extracts the structs from the AST of a file
+// The scope is used to resolve the types used by the file
+func (p *Parser) parseAST(filename string, astFile *ast.File, fileScope scope) (*File, error) {
+	var err error
+
 	// Create the parsed File
 	parsedFile := &File{}
 	parsedFile.Path = FilePath(filename)
 	parsedFile.Package = astFile.Name.Name
+	parsedFile.BuildConstraint = buildConstraint(filename, astFile)
+
+	// Get the file level directive placed above the package clause
+	// It overrides the package level directive and is inherited by the structs
+	packageDefaults, err := p.packageDefaults(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	_, fileDefaults := p.processComment(astFile.Doc.Text(), packageDefaults)
 
 	// Inspect the AST
 	ast.Inspect(astFile, func(node ast.Node) bool {
@@ -94,9 +185,9 @@ func (p *Parser) parseFile(filename string, content []byte) (*File, error) {
 		// (Const, Type, Var)
 		case *ast.GenDecl:
 			{
-				// Get the comment
-				comment, process := p.processComment(node.Doc.Text())
-				if !process {
+				// Get the comment and the directive of the declaration
+				comment, preprocessor := p.processComment(node.Doc.Text(), fileDefaults)
+				if !preprocessor.DoProcess() {
 					return true
 				}
 
@@ -107,7 +198,7 @@ func (p *Parser) parseFile(filename string, content []byte) (*File, error) {
 						{
 							switch spec.Type.(type) {
 							case *ast.StructType:
-								parsedStruct, parseErr := p.parseStruct(spec, comment)
+								parsedStruct, parseErr := p.parseStruct(spec, comment, preprocessor, fileScope)
 								if parseErr != nil {
 									err = parseErr
 									return false
@@ -124,6 +215,9 @@ func (p *Parser) parseFile(filename string, content []byte) (*File, error) {
 		}
 		return true
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	// If the File is empty, return nil
 	if len(parsedFile.Structs) == 0 {
@@ -133,20 +227,49 @@ func (p *Parser) parseFile(filename string, content []byte) (*File, error) {
 	return parsedFile, nil
 }
 
-func (p *Parser) processComment(comment string) (string, bool) {
+// packageDefaults returns the package level directive of the directory
+// It is read from the package comment of the doc.go file, if any
+func (p *Parser) packageDefaults(dir string) (*Preprocessor, error) {
+	p.mu.Lock()
+	defaults, ok := p.packages[dir]
+	p.mu.Unlock()
+	if ok {
+		return defaults, nil
+	}
+
+	// Without a doc.go file, the parser defaults are used
+	defaults = p.preprocessor
+	filename := filepath.Join(dir, "doc.go")
+	if _, err := os.Stat(filename); err == nil {
+		astFile, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		_, defaults = p.processComment(astFile.Doc.Text(), p.preprocessor)
+	}
+
+	p.mu.Lock()
+	p.packages[dir] = defaults
+	p.mu.Unlock()
+	return defaults, nil
+}
+
+// processComment extracts the directive from the comment
+// The directive is parsed on a copy of the defaults, which is returned
+// along with the comment cleaned from the directive
+func (p *Parser) processComment(comment string, defaults *Preprocessor) (string, *Preprocessor) {
 	// Split the comment lines
 	lines := strings.Split(comment, "\n")
 
-	// By default, the file will not be processed
-	// unless the preprocessor is found in the comment
-	process := false
+	// Start from the inherited state
+	preprocessor := defaults.Clone()
 
 	// Check if the comment is a preprocessor
-	if p.preprocessor.preprocessor != "" && len(lines) > 0 {
+	if preprocessor.preprocessor != "" && len(lines) > 0 {
 		for i, line := range lines {
-			if strings.HasPrefix(line, p.preprocessor.preprocessor) {
+			if strings.HasPrefix(line, preprocessor.preprocessor) {
 				// Initialize the preprocessor tags
-				p.preprocessor.Parse(line)
+				preprocessor.Parse(line)
 				// Remove the comment
 				lines = append(lines[:i], lines[i+1:]...)
 				break
@@ -158,18 +281,15 @@ func (p *Parser) processComment(comment string) (string, bool) {
 	comment = strings.Join(lines, "\n")
 	comment = strings.TrimFunc(comment, func(r rune) bool { return r == ' ' || r == '\r' || r == '\n' })
 
-	//
-	process = p.preprocessor.DoProcess()
-
-	// Process all the files even if the preprocessor is not found
-	if !process && p.forceProcess {
-		process = true
+	// Process all the structs even if the preprocessor is not found
+	if !preprocessor.DoProcess() && !preprocessor.Exclude && p.forceProcess {
+		preprocessor.Include = true
 	}
 
-	return comment, process
+	return comment, preprocessor
 }
 
-func (p *Parser) parseStruct(typeSpec *ast.TypeSpec, comment string) (*Struct, error) {
+func (p *Parser) parseStruct(typeSpec *ast.TypeSpec, comment string, preprocessor *Preprocessor, s scope) (*Struct, error) {
 	// Convert to *ast.StructType to check if it is a struct
 	structType, ok := typeSpec.Type.(*ast.StructType)
 	if !ok {
@@ -180,36 +300,115 @@ func (p *Parser) parseStruct(typeSpec *ast.TypeSpec, comment string) (*Struct, e
 	parsedStruct := &Struct{}
 	parsedStruct.Name = typeSpec.Name.Name
 	parsedStruct.Comment = comment
+	parsedStruct.Pos = s.position(typeSpec.Name.Pos())
+	for _, o := range preprocessor.Options {
+		if parsedStruct.Options == nil {
+			parsedStruct.Options = make(map[string]string)
+		}
+		parsedStruct.Options[o.Key] = o.Value
+	}
+
+	// Parse the fields
+	fields, err := p.parseFields(structType, preprocessor, s, map[*ast.StructType]bool{structType: true})
+	if err != nil {
+		return nil, err
+	}
+	parsedStruct.Fields = fields
+
+	// Add the tag keys to the struct if not already added
+	// The tags of an excluded field are ignored
+	for _, parsedField := range parsedStruct.Fields {
+		if parsedField.Exclude {
+			continue
+		}
+		for _, tag := range parsedField.Tags {
+			if parsedStruct.TagKeys == nil {
+				parsedStruct.TagKeys = make([]string, 0)
+			}
+			if !parsedStruct.ContainsTag(tag.Key) {
+				parsedStruct.TagKeys = append(parsedStruct.TagKeys, tag.Key)
+			}
+		}
+	}
+
+	return parsedStruct, nil
+}
+
+// parseFields parses the fields of a struct
+// The embedded structs are flattened when the flatten option is set
+func (p *Parser) parseFields(structType *ast.StructType, preprocessor *Preprocessor, s scope, visiting map[*ast.StructType]bool) ([]Field, error) {
+	fields := make([]Field, 0)
+
+	// The fields inherit the struct directive, except for the name which is field specific
+	fieldDefaults := preprocessor.Clone()
+	fieldDefaults.RemoveOption("name")
+
+	// The promoted fields are shadowed by the fields declared in the struct
+	declared := make(map[string]bool)
+	for _, field := range structType.Fields.List {
+		for _, fieldName := range field.Names {
+			declared[fieldName.Name] = true
+		}
+	}
 
 	// Iterate over the fields
 	for _, field := range structType.Fields.List {
-		// Get the comment
-		comment := field.Doc.Text()
+		// Get the comment and the field directive
+		// The directive can be placed in the doc or in the line comment
+		comment, fieldPreprocessor := p.processComment(field.Doc.Text(), fieldDefaults)
+		_, fieldPreprocessor = p.processComment(field.Comment.Text(), fieldPreprocessor)
+		alias, _ := fieldPreprocessor.GetOption("name")
+
+		// The same alias on several names would declare the same identifiers
+		if alias != "" && len(field.Names) > 1 {
+			return nil, fmt.Errorf("%s: the name %s cannot be given to the fields %s", s.position(field.Pos()), alias, fieldNames(field))
+		}
+
+		// Flatten the embedded field
+		if len(field.Names) == 0 && p.flatten {
+			promotedFields, err := p.parseEmbedded(field, comment, fieldPreprocessor, s, visiting)
+			if err != nil {
+				return nil, err
+			}
+			for _, promotedField := range promotedFields {
+				if !declared[promotedField.Name] {
+					declared[promotedField.Name] = true
+					fields = append(fields, promotedField)
+				}
+			}
+			continue
+		}
+
 		// Iterate over the field names
 		for _, fieldName := range field.Names {
 			// Create the parsed Field
 			parsedField := &Field{}
 			parsedField.Name = fieldName.Name
 			parsedField.Comment = comment
-			parsedField.Type = p.parseType(field.Type)
-			parsedField.Tags = p.parseTags(field.Tag)
-
-			// Add the tag keys to the struct if not already added
-			for _, tag := range parsedField.Tags {
-				if parsedStruct.TagKeys == nil {
-					parsedStruct.TagKeys = make([]string, 0)
-				}
-				if !parsedStruct.ContainsTag(tag.Key) {
-					parsedStruct.TagKeys = append(parsedStruct.TagKeys, tag.Key)
-				}
+			parsedField.Type = p.typeString(field.Type, s)
+			parsedField.Tags = p.parseTags(field.Tag, fieldPreprocessor)
+			if p.dialects {
+				parsedField.Tags = resolveDialects(parsedField.Tags, fieldName.Name)
 			}
+			parsedField.Exclude = fieldPreprocessor.Exclude
+			parsedField.Alias = alias
+			parsedField.Pos = s.position(fieldName.Pos())
 
 			// Add the field to the struct
-			parsedStruct.Fields = append(parsedStruct.Fields, *parsedField)
+			fields = append(fields, *parsedField)
 		}
 	}
 
-	return parsedStruct, nil
+	return fields, nil
+}
+
+// fieldNames returns the names of a field declaration separated by commas
+func fieldNames(field *ast.Field) string {
+	names := make([]string, 0, len(field.Names))
+	for _, name := range field.Names {
+		names = append(names, name.Name)
+	}
+	return strings.Join(names, ", ")
 }
 
 func (p *Parser) parseType(expr ast.Expr) string {
@@ -237,7 +436,7 @@ func (p *Parser) parseType(expr ast.Expr) string {
 	}
 }
 
-func (p *Parser) parseTags(tag *ast.BasicLit) []tags.Tag {
+func (p *Parser) parseTags(tag *ast.BasicLit, preprocessor *Preprocessor) []tags.Tag {
 	if tag == nil {
 		return nil
 	}
@@ -257,7 +456,7 @@ func (p *Parser) parseTags(tag *ast.BasicLit) []tags.Tag {
 	tagsSlice := make([]tags.Tag, 0, len(tagList))
 	for _, t := range tagList {
 		// check if the tag is in the tags map
-		if p.preprocessor.ShouldProcess(t.Key) {
+		if preprocessor.ShouldProcess(t.Key) {
 			tagsSlice = append(tagsSlice, *t)
 		}
 	}
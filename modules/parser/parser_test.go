@@ -2,6 +2,10 @@ package parser
 
 import (
 	"github.com/go-mods/tags"
+	"github.com/go-mods/tagsvar/modules/config"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -215,3 +219,502 @@ func TestParser_parseFile(t *testing.T) {
 
 	}
 }
+
+func TestParser_parseFileOrder(t *testing.T) {
+	// Structs declared in the file, with the tags expected for each of them
+	// A nil value means that the struct must not be generated
+	var structs = []struct {
+		name   string
+		source string
+		tags   []string
+	}{
+		{
+			name:   "All",
+			source: "// #tagsvar\ntype All struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			tags:   []string{"json", "xml"},
+		},
+		{
+			name:   "NoXml",
+			source: "// #tagsvar:exclude:xml\ntype NoXml struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			tags:   []string{"json"},
+		},
+		{
+			name:   "OnlyXml",
+			source: "// #tagsvar:include:xml\ntype OnlyXml struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			tags:   []string{"xml"},
+		},
+		{
+			name:   "NoDirective",
+			source: "// NoDirective has no directive\ntype NoDirective struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			tags:   nil,
+		},
+		{
+			name:   "Excluded",
+			source: "// #tagsvar:exclude\ntype Excluded struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			tags:   nil,
+		},
+	}
+
+	// permutations returns all the orderings of the indexes
+	var permutations func(indexes []int) [][]int
+	permutations = func(indexes []int) [][]int {
+		if len(indexes) <= 1 {
+			return [][]int{indexes}
+		}
+		var result [][]int
+		for i := range indexes {
+			rest := append(append([]int{}, indexes[:i]...), indexes[i+1:]...)
+			for _, perm := range permutations(rest) {
+				result = append(result, append([]int{indexes[i]}, perm...))
+			}
+		}
+		return result
+	}
+
+	parser := NewParser()
+
+	for _, order := range permutations([]int{0, 1, 2, 3, 4}) {
+		// Build the file content
+		content := "package testdata\n\n"
+		for _, i := range order {
+			content += structs[i].source + "\n"
+		}
+
+		parsed, err := parser.parseFile("order.go", []byte(content))
+		if err != nil {
+			t.Fatalf("parseFile() error = %v", err)
+		}
+
+		// Index the parsed structs by name
+		parsedStructs := make(map[string]Struct)
+		if parsed != nil {
+			for _, s := range parsed.Structs {
+				parsedStructs[s.Name] = s
+			}
+		}
+
+		for _, s := range structs {
+			parsedStruct, ok := parsedStructs[s.name]
+			if s.tags == nil {
+				if ok {
+					t.Errorf("parseFile() order %v: struct %s should not be parsed", order, s.name)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("parseFile() order %v: struct %s should be parsed", order, s.name)
+				continue
+			}
+			if len(parsedStruct.TagKeys) != len(s.tags) {
+				t.Errorf("parseFile() order %v: struct %s got = %v, want %v", order, s.name, parsedStruct.TagKeys, s.tags)
+				continue
+			}
+			for i, key := range parsedStruct.TagKeys {
+				if key != s.tags[i] {
+					t.Errorf("parseFile() order %v: struct %s got = %v, want %v", order, s.name, parsedStruct.TagKeys, s.tags)
+				}
+			}
+		}
+	}
+}
+
+func TestParser_parseFileDirective(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		want    map[string][]string
+	}{
+		{
+			name: "file directive",
+			content: "// #tagsvar:include:json\npackage testdata\n\n" +
+				"type User struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n\n" +
+				"type Blog struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			want: map[string][]string{"User": {"json"}, "Blog": {"json"}},
+		},
+		{
+			name: "struct directive overrides file directive",
+			content: "// #tagsvar:include:json\npackage testdata\n\n" +
+				"// #tagsvar:include:xml\ntype User struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n\n" +
+				"// #tagsvar:exclude\ntype Blog struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			want: map[string][]string{"User": {"xml"}},
+		},
+		{
+			name: "struct include all overrides file directive",
+			content: "// #tagsvar:include:json\npackage testdata\n\n" +
+				"// #tagsvar:include:all\ntype User struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n\n" +
+				"// #tagsvar:all\ntype Blog struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			want: map[string][]string{"User": {"json", "xml"}, "Blog": {"json", "xml"}},
+		},
+		{
+			name: "file exclude",
+			content: "// #tagsvar:exclude\npackage testdata\n\n" +
+				"type User struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n\n" +
+				"// #tagsvar\ntype Blog struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+			want: map[string][]string{"Blog": {"json", "xml"}},
+		},
+	}
+
+	for _, test := range tests {
+		parsed, err := NewParser().parseFile("directive.go", []byte(test.content))
+		if err != nil {
+			t.Fatalf("%s: parseFile() error = %v", test.name, err)
+		}
+		assertTagKeys(t, test.name, parsed, test.want)
+	}
+}
+
+func TestParser_ParseDirPackageDirective(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"doc.go": "// Package models contains the models\n// #tagsvar:include:json\npackage models\n",
+		"user.go": "package models\n\n" +
+			"type User struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+		"blog.go": "// #tagsvar:include:xml\npackage models\n\n" +
+			"type Blog struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n\n" +
+			"// #tagsvar:exclude:xml\ntype Post struct {\n\tID int `json:\"id\" xml:\"id\"`\n}\n",
+	}
+	writeFiles(t, dir, files)
+
+	parsedFiles, err := NewParser().ParseDir(dir, false)
+	if err != nil {
+		t.Fatalf("ParseDir() error = %v", err)
+	}
+
+	assertTagKeys(t, "user.go", parsedFiles[FilePath(filepath.Join(dir, "user.go"))], map[string][]string{"User": {"json"}})
+	assertTagKeys(t, "blog.go", parsedFiles[FilePath(filepath.Join(dir, "blog.go"))], map[string][]string{"Blog": {"xml"}, "Post": {"json"}})
+	assertTagKeys(t, "doc.go", parsedFiles[FilePath(filepath.Join(dir, "doc.go"))], nil)
+}
+
+// assertTagKeys checks the tag keys of each parsed struct
+func assertTagKeys(t *testing.T, name string, parsed *File, want map[string][]string) {
+	t.Helper()
+
+	got := make(map[string][]string)
+	if parsed != nil {
+		for _, s := range parsed.Structs {
+			got[s.Name] = s.TagKeys
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("%s: got = %v, want %v", name, got, want)
+		return
+	}
+	for structName, keys := range want {
+		if strings.Join(got[structName], ",") != strings.Join(keys, ",") {
+			t.Errorf("%s: struct %s got = %v, want %v", name, structName, got[structName], keys)
+		}
+	}
+}
+
+// writeFiles writes the files in the directory
+func writeFiles(tb testing.TB, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestParser_parseFieldDirective(t *testing.T) {
+	content := "package testdata\n\n" +
+		"// #tagsvar:include:json,xml:name=Account\n" +
+		"type User struct {\n" +
+		"\t// #tagsvar:name=PrimaryKey\n" +
+		"\tID int `json:\"id\" xml:\"id\"`\n" +
+		"\t// Password is never exposed\n" +
+		"\t// #tagsvar:exclude\n" +
+		"\tPassword string `json:\"password\" xml:\"password\"`\n" +
+		"\tEmail string `json:\"email\" xml:\"email\"` // #tagsvar:exclude:xml\n" +
+		"\tName string `json:\"name\" xml:\"name\"`\n" +
+		"}\n"
+
+	parsed, err := NewParser().parseFile("field.go", []byte(content))
+	if err != nil || parsed == nil || len(parsed.Structs) != 1 {
+		t.Fatalf("parseFile() got = %v, error = %v", parsed, err)
+	}
+
+	var fields = []struct {
+		name    string
+		comment string
+		exclude bool
+		alias   string
+		tags    int
+		line    int
+	}{
+		{name: "ID", alias: "PrimaryKey", tags: 2, line: 6},
+		{name: "Password", comment: "Password is never exposed", exclude: true, tags: 0, line: 9},
+		{name: "Email", tags: 1, line: 10},
+		{name: "Name", tags: 2, line: 11},
+	}
+
+	s := parsed.Structs[0]
+	if s.Pos.Filename != "field.go" || s.Pos.Line != 4 {
+		t.Errorf("parseFile() struct position = %v, want field.go:4", s.Pos)
+	}
+	if s.Options["name"] != "Account" {
+		t.Errorf("parseFile() struct options = %v, want name=Account", s.Options)
+	}
+	if len(s.Fields) != len(fields) {
+		t.Fatalf("parseFile() got = %v, want %v", len(s.Fields), len(fields))
+	}
+	for i, want := range fields {
+		f := s.Fields[i]
+		if f.Name != want.name || f.Comment != want.comment || f.Exclude != want.exclude || f.Alias != want.alias || len(f.Tags) != want.tags || f.Pos.Line != want.line {
+			t.Errorf("parseFile() got = %+v, want %+v", f, want)
+		}
+	}
+	if strings.Join(s.TagKeys, ",") != "json,xml" {
+		t.Errorf("parseFile() got = %v, want %v", s.TagKeys, []string{"json", "xml"})
+	}
+}
+
+func TestParser_parseFieldDirectiveMultiName(t *testing.T) {
+	content := "package testdata\n\n" +
+		"// #tagsvar\n" +
+		"type Point struct {\n" +
+		"\t// #tagsvar:name=Coord\n" +
+		"\tX, Y int `json:\"x\"`\n" +
+		"}\n"
+
+	_, err := NewParser().parseFile("point.go", []byte(content))
+	if err == nil || !strings.HasPrefix(err.Error(), "point.go:6:") || !strings.Contains(err.Error(), "X, Y") {
+		t.Errorf("parseFile() error = %v, want a positioned error on the fields X, Y", err)
+	}
+}
+
+func TestParser_parseEmbedded(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n",
+		"base/entity.go": "package base\n\n" +
+			"type Entity struct {\n" +
+			"\tID        int `json:\"id\" gorm:\"id\"`\n" +
+			"\tCreatedAt int `json:\"created_at\" gorm:\"created_at\"`\n" +
+			"}\n",
+		"models/audit.go": "package models\n\n" +
+			"type Audit struct {\n" +
+			"\tBy string `json:\"by\" gorm:\"by\"`\n" +
+			"\tAt int    `json:\"at\" gorm:\"at\"`\n" +
+			"}\n\n" +
+			"type Level int\n",
+		"models/user.go": "package models\n\n" +
+			"import (\n" +
+			"\tentity \"example.com/app/base\"\n" +
+			"\t\"example.com/missing\"\n" +
+			")\n\n" +
+			"// #tagsvar\n" +
+			"type User struct {\n" +
+			"\tentity.Entity\n" +
+			"\tmissing.Stamp `json:\"stamp\"`\n" +
+			"\tLevel `json:\"level\"`\n" +
+			"\t*Audit `json:\"audit\" gorm:\"embedded;embeddedPrefix:audit_\"`\n" +
+			"\tCreatedAt int `json:\"created\" gorm:\"created\"`\n" +
+			"\tName      string `json:\"name\" gorm:\"name\"`\n" +
+			"}\n",
+	}
+	writeFiles(t, dir, files)
+
+	var tests = []struct {
+		flatten bool
+		fields  map[string]map[string]string
+	}{
+		{
+			flatten: false,
+			fields: map[string]map[string]string{
+				"CreatedAt": {"json": "created", "gorm": "created"},
+				"Name":      {"json": "name", "gorm": "name"},
+			},
+		},
+		{
+			flatten: true,
+			fields: map[string]map[string]string{
+				"ID":        {"json": "id", "gorm": "id"},
+				"Stamp":     {"json": "stamp"},
+				"Level":     {"json": "level"},
+				"Audit":     {"json": "audit"},
+				"By":        {"gorm": "audit_by"},
+				"At":        {"gorm": "audit_at"},
+				"CreatedAt": {"json": "created", "gorm": "created"},
+				"Name":      {"json": "name", "gorm": "name"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		p := NewParser()
+		p.flatten = test.flatten
+
+		parsed, err := p.ParseFile(filepath.Join(dir, "models", "user.go"))
+		if err != nil || parsed == nil || len(parsed.Structs) != 1 {
+			t.Fatalf("ParseFile() got = %v, error = %v", parsed, err)
+		}
+
+		got := make(map[string]map[string]string)
+		for _, f := range parsed.Structs[0].Fields {
+			got[f.Name] = make(map[string]string)
+			for _, tag := range f.Tags {
+				got[f.Name][tag.Key] = tag.Name
+			}
+		}
+
+		if len(got) != len(test.fields) {
+			t.Errorf("ParseFile() flatten %v got = %v, want %v", test.flatten, got, test.fields)
+			continue
+		}
+		for name, want := range test.fields {
+			if len(got[name]) != len(want) {
+				t.Errorf("ParseFile() flatten %v field %s got = %v, want %v", test.flatten, name, got[name], want)
+				continue
+			}
+			for key, tagName := range want {
+				if got[name][key] != tagName {
+					t.Errorf("ParseFile() flatten %v field %s got = %v, want %v", test.flatten, name, got[name], want)
+				}
+			}
+		}
+	}
+}
+
+func TestParser_ParseDirPackages(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n",
+		"types.go": "package models\n\n" +
+			"import \"time\"\n\n" +
+			"type List[T any] []T\n\n" +
+			"type Base struct {\n\tCreatedAt time.Time `json:\"created_at\"`\n}\n",
+		"user.go": "package models\n\n" +
+			"// #tagsvar\n" +
+			"type User struct {\n" +
+			"\tBase\n" +
+			"\tTags  List[string] `json:\"tags\"`\n" +
+			"\tHash  [32]byte     `json:\"hash\"`\n" +
+			"\tOwner *Base        `json:\"owner\"`\n" +
+			"}\n",
+		"ignored.go": "//go:build ignore\n\npackage models\n\n" +
+			"// #tagsvar\n" +
+			"type Ignored struct {\n\tID int `json:\"id\"`\n}\n",
+	}
+	writeFiles(t, dir, files)
+
+	p := NewParser()
+	p.typed = true
+	p.flatten = true
+
+	parsedFiles, err := p.ParseDir(dir, false)
+	if err != nil {
+		t.Fatalf("ParseDir() error = %v", err)
+	}
+
+	// The file excluded by the build constraint is not loaded
+	if _, ok := parsedFiles[FilePath(filepath.Join(dir, "ignored.go"))]; ok {
+		t.Errorf("ParseDir() should not load ignored.go")
+	}
+
+	parsed := parsedFiles[FilePath(filepath.Join(dir, "user.go"))]
+	if parsed == nil || len(parsed.Structs) != 1 {
+		t.Fatalf("ParseDir() got = %v", parsed)
+	}
+	if parsed.Package != "models" {
+		t.Errorf("ParseDir() got = %v, want %v", parsed.Package, "models")
+	}
+
+	want := map[string]string{
+		"CreatedAt": "time.Time",
+		"Tags":      "List[string]",
+		"Hash":      "[32]byte",
+		"Owner":     "*Base",
+	}
+	got := make(map[string]string)
+	for _, f := range parsed.Structs[0].Fields {
+		got[f.Name] = f.Type
+	}
+	if len(got) != len(want) {
+		t.Errorf("ParseDir() got = %v, want %v", got, want)
+	}
+	for name, typ := range want {
+		if got[name] != typ {
+			t.Errorf("ParseDir() field %s got = %v, want %v", name, got[name], typ)
+		}
+	}
+}
+
+func TestParser_ParseDirPackagesListFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	source := "package models\n\n// #tagsvar\ntype User struct {\n\tID int `json:\"id\"`\n}\n"
+	files := map[string]string{
+		"go.mod":                     "module example.com/app\n\ngo 1.21\n",
+		"models/user.go":             source,
+		"models/skip/user.go":        strings.Replace(source, "models", "skip", 1),
+		"models/.hidden/user.go":     strings.Replace(source, "models", "hidden", 1),
+		"models/vendor/user.go":      strings.Replace(source, "models", "vendor", 1),
+		"models/admin/.tagsvar.yaml": "helpers: true\n",
+		"models/admin/user.go":       strings.Replace(source, "models", "admin", 1),
+	}
+	writeFiles(t, dir, files)
+
+	defer func(c config.AppConfig) { *config.C = c }(*config.C)
+	config.C.Exclude = "skip"
+
+	p := NewParser()
+	p.typed = true
+
+	parsedFiles, err := p.ParseDir(dir, true)
+	if err != nil {
+		t.Fatalf("ParseDir() error = %v", err)
+	}
+
+	// The packages backend lists the files like the ast backend,
+	// the subdirectories with their own configuration are parsed with it
+	want := []string{filepath.Join(dir, "models", "user.go")}
+	got := make([]string, 0, len(parsedFiles))
+	for path := range parsedFiles {
+		got = append(got, string(path))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ParseDir() got = %v, want %v", got, want)
+	}
+}
+
+func TestParser_ParseFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"user.go": "package models\n\n// #tagsvar\ntype User struct {\n\tID int `json:\"id\"`\n}\n",
+		"post.go": "package models\n\n// #tagsvar\ntype Post struct {\n\tID int `json:\"id\"`\n}\n",
+		"tag.go":  "package models\n\ntype Tag struct {\n\tID int `json:\"id\"`\n}\n",
+	}
+	writeFiles(t, dir, files)
+	user, tag := filepath.Join(dir, "user.go"), filepath.Join(dir, "tag.go")
+
+	for _, typed := range []bool{false, true} {
+		p := NewParser()
+		p.typed = typed
+
+		parsedFiles, err := p.ParseFiles([]string{user, tag})
+		if err != nil {
+			t.Fatalf("ParseFiles() typed=%v error = %v", typed, err)
+		}
+
+		// Only the requested files are parsed
+		if len(parsedFiles) != 2 {
+			t.Errorf("ParseFiles() typed=%v got %d files, want 2", typed, len(parsedFiles))
+		}
+		if parsed := parsedFiles[FilePath(user)]; parsed == nil || len(parsed.Structs) != 1 || parsed.Structs[0].Name != "User" {
+			t.Errorf("ParseFiles() typed=%v user.go got = %v", typed, parsed)
+		}
+		if parsed, ok := parsedFiles[FilePath(tag)]; !ok || parsed != nil {
+			t.Errorf("ParseFiles() typed=%v tag.go got = %v, want nil", typed, parsed)
+		}
+	}
+}
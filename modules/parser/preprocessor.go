@@ -23,13 +23,24 @@ import (
 //
 // Exclude is the default behavior, so if the preprocessor is not found in the comment, the struct will be excluded
 // If the preprocessor is found and contains the include and exclude keywords, the exclude keyword will have the priority
+//
+// Key value options can be appended to the directive. They are kept apart from the include
+// and exclude keywords, so a directive made only of options does not change the included tags
+//
+//	#tagsvar:name=PrimaryKey -> generate the field under the PrimaryKey identifier
+//	#tagsvar:exclude:json:name=PrimaryKey -> options can be combined with the keywords
+//	#tagsvar:include:mode=namespace -> the struct options are given to the generator (see Struct.Options)
+//
+// The directive can also be placed above the package clause to apply to every struct of the file,
+// or in the package comment of the doc.go file to apply to every struct of the package.
+// A struct directive overrides the file directive, which overrides the package directive
 type Preprocessor struct {
 	// This is the preprocessor string name
 	// The default value is #tagsvar
 	preprocessor string
 
 	// Include is a boolean that indicates if the struct should be included or not
-	// The default value is true
+	// The default value is false
 	Include bool
 
 	// IncludeTags is a list of tags that should be included
@@ -43,6 +54,11 @@ type Preprocessor struct {
 	// ExcludeTags is a list of tags that should be excluded
 	// The default value is nil
 	ExcludeTags []string
+
+	// Options is a list of key value options (key=value)
+	// The options are inherited and overridden by key
+	// The default value is nil
+	Options []Option
 }
 
 // Option is a simple key, value pair
@@ -52,16 +68,27 @@ type Option struct {
 }
 
 // NewPreprocessor returns a new preprocessor
+// Nothing is included until a directive is parsed
 func NewPreprocessor() *Preprocessor {
 	return &Preprocessor{
 		preprocessor: "#tagsvar",
-		Include:      true,
+		Include:      false,
 		IncludeTags:  nil,
 		Exclude:      false,
 		ExcludeTags:  nil,
 	}
 }
 
+// Clone returns a copy of the preprocessor
+// The copy can be parsed without altering the original
+func (p *Preprocessor) Clone() *Preprocessor {
+	c := *p
+	c.IncludeTags = append([]string(nil), p.IncludeTags...)
+	c.ExcludeTags = append([]string(nil), p.ExcludeTags...)
+	c.Options = append([]Option(nil), p.Options...)
+	return &c
+}
+
 // Parse parses the string and extract the options
 func (p *Preprocessor) Parse(s string) {
 	// Clean the string
@@ -75,7 +102,14 @@ func (p *Preprocessor) Parse(s string) {
 	// The first element is the preprocessor
 	// The second element is the include or exclude keyword
 	// The third element is the tags
-	split := strings.Split(s, ":")
+	// The options (key=value) can be placed anywhere after the preprocessor
+	elements := strings.Split(s, ":")
+	split := p.parseOptions(elements)
+
+	// If the directive only contains options, the included tags are unchanged
+	if len(split) == 1 && len(elements) > 1 {
+		return
+	}
 
 	// If the length of the split is 1, it means that the preprocessor is the only element
 	// So the struct should be included and all the tags should be included
@@ -90,8 +124,8 @@ func (p *Preprocessor) Parse(s string) {
 	// If the length of the split is 2, it means that the preprocessor and the keyword are the only elements
 	// So the struct should be included or excluded and all the tags should be included
 	if len(split) == 2 {
-		// Check if the keyword is include
-		if split[1] == "include" {
+		// Check if the keyword is include or all
+		if split[1] == "include" || split[1] == "all" {
 			p.Include = true
 			p.IncludeTags = nil
 			p.Exclude = false
@@ -116,6 +150,7 @@ func (p *Preprocessor) Parse(s string) {
 		if split[1] == "include" {
 			p.Include = true
 			if split[2] == "all" {
+				p.IncludeTags = nil
 			} else {
 				p.IncludeTags = strings.Split(split[2], ",")
 			}
@@ -142,6 +177,53 @@ func (p *Preprocessor) Parse(s string) {
 	}
 }
 
+// parseOptions extracts the options from the split directive
+// It returns the remaining elements
+func (p *Preprocessor) parseOptions(split []string) []string {
+	remaining := make([]string, 0, len(split))
+	for i, element := range split {
+		key, value, found := strings.Cut(element, "=")
+		if i == 0 || !found {
+			remaining = append(remaining, element)
+			continue
+		}
+		p.SetOption(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	return remaining
+}
+
+// SetOption sets the value of an option
+// An existing option with the same key is overridden
+func (p *Preprocessor) SetOption(key string, value string) {
+	for i, o := range p.Options {
+		if o.Key == key {
+			p.Options[i].Value = value
+			return
+		}
+	}
+	p.Options = append(p.Options, Option{Key: key, Value: value})
+}
+
+// RemoveOption removes an option
+func (p *Preprocessor) RemoveOption(key string) {
+	for i, o := range p.Options {
+		if o.Key == key {
+			p.Options = append(p.Options[:i], p.Options[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetOption returns the value of an option and true if the option is found
+func (p *Preprocessor) GetOption(key string) (string, bool) {
+	for _, o := range p.Options {
+		if o.Key == key {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
 // DoProcess returns true if the struct should be included and false if the struct should be excluded
 func (p *Preprocessor) DoProcess() bool {
 	// If the struct should be excluded, return false
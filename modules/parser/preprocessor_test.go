@@ -102,6 +102,16 @@ func TestPreprocessor_Init(t *testing.T) {
 				ExcludeTags:  []string{"json", "xml"},
 			},
 		},
+		{
+			comment: `#tagsvar:all`,
+			want: &Preprocessor{
+				preprocessor: preprocessor,
+				Include:      true,
+				IncludeTags:  nil,
+				Exclude:      false,
+				ExcludeTags:  nil,
+			},
+		},
 	}
 
 	// Prepare the preprocessor
@@ -129,3 +139,103 @@ func TestPreprocessor_Init(t *testing.T) {
 		}
 	}
 }
+
+func TestPreprocessor_Clone(t *testing.T) {
+	var tests = []struct {
+		comment string
+		process bool
+		tags    map[string]bool
+	}{
+		{comment: ``, process: false, tags: map[string]bool{"json": false, "xml": false}},
+		{comment: `#tagsvar`, process: true, tags: map[string]bool{"json": true, "xml": true}},
+		{comment: `#tagsvar:exclude:xml`, process: true, tags: map[string]bool{"json": true, "xml": false}},
+		{comment: `#tagsvar:include:xml`, process: true, tags: map[string]bool{"json": false, "xml": true}},
+		{comment: `#tagsvar:exclude`, process: false, tags: map[string]bool{"json": false, "xml": false}},
+	}
+
+	// The defaults must never be altered by the clones
+	defaults := NewPreprocessor()
+
+	// Run the tests twice in both orders, the result must not depend on the previous comment
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 0, 3, 1, 4, 2}} {
+		for _, i := range order {
+			test := tests[i]
+			p := defaults.Clone()
+			p.Parse(test.comment)
+
+			if p.DoProcess() != test.process {
+				t.Errorf("DoProcess(%q) got = %v, want %v", test.comment, p.DoProcess(), test.process)
+			}
+			for tag, want := range test.tags {
+				if p.ShouldProcess(tag) != want {
+					t.Errorf("ShouldProcess(%q) with %q got = %v, want %v", tag, test.comment, p.ShouldProcess(tag), want)
+				}
+			}
+		}
+
+		if defaults.DoProcess() || defaults.IncludeTags != nil || defaults.ExcludeTags != nil {
+			t.Errorf("Clone() altered the defaults: %+v", defaults)
+		}
+	}
+}
+
+func TestPreprocessor_Options(t *testing.T) {
+	var tests = []struct {
+		comment string
+		options map[string]string
+		process bool
+		tags    map[string]bool
+	}{
+		{
+			comment: `#tagsvar:name=PrimaryKey`,
+			options: map[string]string{"name": "PrimaryKey"},
+			process: true,
+			tags:    map[string]bool{"json": true, "xml": false},
+		},
+		{
+			comment: `#tagsvar:exclude:xml:name=PrimaryKey`,
+			options: map[string]string{"name": "PrimaryKey"},
+			process: true,
+			tags:    map[string]bool{"json": true, "xml": false},
+		},
+		{
+			comment: `#tagsvar:name=Key:include:xml`,
+			options: map[string]string{"name": "Key"},
+			process: true,
+			tags:    map[string]bool{"json": false, "xml": true},
+		},
+		{
+			comment: `#tagsvar:exclude`,
+			options: map[string]string{"name": ""},
+			process: false,
+			tags:    map[string]bool{"json": false, "xml": false},
+		},
+	}
+
+	// The inherited state includes the json tag only
+	defaults := NewPreprocessor()
+	defaults.Parse(`#tagsvar:include:json`)
+
+	for _, test := range tests {
+		p := defaults.Clone()
+		p.Parse(test.comment)
+
+		if p.DoProcess() != test.process {
+			t.Errorf("DoProcess(%q) got = %v, want %v", test.comment, p.DoProcess(), test.process)
+		}
+		for key, want := range test.options {
+			if got, _ := p.GetOption(key); got != want {
+				t.Errorf("GetOption(%q) with %q got = %v, want %v", key, test.comment, got, want)
+			}
+		}
+		for tag, want := range test.tags {
+			if p.ShouldProcess(tag) != want {
+				t.Errorf("ShouldProcess(%q) with %q got = %v, want %v", tag, test.comment, p.ShouldProcess(tag), want)
+			}
+		}
+	}
+
+	if len(defaults.Options) != 0 {
+		t.Errorf("Clone() altered the defaults: %+v", defaults)
+	}
+}
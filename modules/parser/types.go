@@ -1,36 +1,63 @@
 package parser
 
-import "github.com/go-mods/tags"
+import (
+	"github.com/go-mods/tags"
+	"go/token"
+)
 
 type FilePath string
 
 // File represents a project file
 // It contains the path of the file, the package name and the structs
 // This information are extracted from the file and will be used to generate the variables files
+//
+// BuildConstraint is the build constraint of the file, read from its //go:build line
+// and from its name suffixes (_windows.go), or an empty string
 type File struct {
-	Path    FilePath
-	Package string
-	Structs []Struct
+	Path            FilePath
+	Package         string
+	BuildConstraint string
+	Structs         []Struct
 }
 
 // Struct represents a struct in a project file
 // It contains the name of the struct and the fields
 // This information are extracted from the file and will be used to generate the variables files
+//
+// Pos is the position of the struct name in the source file
+// Options are the key=value options of the struct directive, inherited from the file and package directives
 type Struct struct {
 	Name    string
 	Comment string
 	Fields  []Field
 	TagKeys []string
+	Pos     token.Position
+	Options map[string]string
 }
 
 // Field represents a field in a struct
 // It contains the name of the field, the type and the tags
 // This information are extracted from the file and will be used to generate the variables files
+//
+// Exclude and Alias are set from the field directive (#tagsvar:exclude, #tagsvar:name=Alias)
+// Pos is the position of the field name in the source file, the promoted fields keep the position of their declaration
 type Field struct {
 	Name    string
 	Comment string
 	Type    string
 	Tags    []tags.Tag
+	Exclude bool
+	Alias   string
+	Pos     token.Position
+}
+
+// Identifier returns the name used to generate the identifiers of the field
+// It is the alias if set, otherwise the name of the field
+func (f *Field) Identifier() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
 }
 
 func (s *Struct) ContainsTag(key string) bool {
@@ -0,0 +1,162 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+// Changes are the files changed since the previous report
+type Changes struct {
+	// Modified are the files created or modified, sorted by path
+	Modified []string
+	// Removed are the files removed, sorted by path
+	Removed []string
+}
+
+// Empty returns true if no file has changed
+func (c Changes) Empty() bool {
+	return len(c.Modified) == 0 && len(c.Removed) == 0
+}
+
+// state is the state of a file used to detect its changes
+type state struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher polls the files listed by a function and reports their changes
+// A file is changed when its modification time or its size changes
+//
+// The list function is called at each poll, so that the created files are detected
+type Watcher struct {
+	// list lists the watched files
+	list func() ([]string, error)
+	// interval is the delay between two polls
+	interval time.Duration
+	// debounce is the delay without any change before the changes are reported
+	debounce time.Duration
+	// states are the states of the files at the previous poll
+	states map[string]state
+}
+
+// NewWatcher creates a new Watcher
+// The files are listed immediately, the changes are reported against this first list
+func NewWatcher(list func() ([]string, error), interval time.Duration, debounce time.Duration) (*Watcher, error) {
+	w := &Watcher{
+		list:     list,
+		interval: interval,
+		debounce: debounce,
+	}
+	states, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.states = states
+	return w, nil
+}
+
+// snapshot returns the states of the listed files
+// The files removed between the listing and the stat are skipped
+func (w *Watcher) snapshot() (map[string]state, error) {
+	files, err := w.list()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]state, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		states[file] = state{modTime: info.ModTime(), size: info.Size()}
+	}
+	return states, nil
+}
+
+// Poll returns the files changed since the previous poll
+func (w *Watcher) Poll() (Changes, error) {
+	var changes Changes
+
+	states, err := w.snapshot()
+	if err != nil {
+		return changes, err
+	}
+
+	for file, s := range states {
+		if previous, ok := w.states[file]; !ok || previous != s {
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+	for file := range w.states {
+		if _, ok := states[file]; !ok {
+			changes.Removed = append(changes.Removed, file)
+		}
+	}
+	w.states = states
+
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Removed)
+	return changes, nil
+}
+
+// Watch polls the files until the context is done and calls the function with the changes
+// The changes are reported once no file has changed during the debounce delay, so that
+// a burst of saves is reported once
+func (w *Watcher) Watch(ctx context.Context, fn func(Changes)) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	// pending are the changes not reported yet, true for the removed files
+	pending := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changes, err := w.Poll()
+		if err != nil {
+			return err
+		}
+		if !changes.Empty() {
+			for _, file := range changes.Modified {
+				pending[file] = false
+			}
+			for _, file := range changes.Removed {
+				pending[file] = true
+			}
+			lastChange = time.Now()
+			continue
+		}
+
+		if len(pending) > 0 && time.Since(lastChange) >= w.debounce {
+			fn(merge(pending))
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// merge returns the changes of the pending files
+func merge(pending map[string]bool) Changes {
+	var changes Changes
+	for file, removed := range pending {
+		if removed {
+			changes.Removed = append(changes.Removed, file)
+		} else {
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Removed)
+	return changes
+}
@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Poll(t *testing.T) {
+	dir := t.TempDir()
+	user, post, tag := filepath.Join(dir, "user.go"), filepath.Join(dir, "post.go"), filepath.Join(dir, "tag.go")
+	write := func(path string, content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(user, "package models\n")
+	write(post, "package models\n")
+
+	list := func() ([]string, error) {
+		return filepath.Glob(filepath.Join(dir, "*.go"))
+	}
+	w, err := NewWatcher(list, time.Millisecond, time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	changes, err := w.Poll()
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if !changes.Empty() {
+		t.Errorf("Poll() = %v, want no change", changes)
+	}
+
+	write(user, "package models\n\ntype User struct{}\n")
+	write(tag, "package models\n")
+	if err := os.Remove(post); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = w.Poll()
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	want := Changes{Modified: []string{tag, user}, Removed: []string{post}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Poll() = %v, want %v", changes, want)
+	}
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.go")
+
+	list := func() ([]string, error) {
+		return filepath.Glob(filepath.Join(dir, "*.go"))
+	}
+	w, err := NewWatcher(list, 5*time.Millisecond, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A burst of saves is reported once
+	go func() {
+		for i := 0; i < 5; i++ {
+			if err := os.WriteFile(user, []byte("package models\n"+string(rune('a'+i))), 0600); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	var reported []Changes
+	err = w.Watch(ctx, func(changes Changes) {
+		reported = append(reported, changes)
+		cancel()
+	})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	want := []Changes{{Modified: []string{user}}}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("Watch() reported %v, want %v", reported, want)
+	}
+}
@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"github.com/go-mods/tagsvar/modules/config"
+	"runtime"
+	"sync"
+)
+
+// Jobs returns the number of workers of the configuration
+// It defaults to GOMAXPROCS
+func Jobs() int {
+	if config.C.Jobs > 0 {
+		return config.C.Jobs
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Run calls the function for the indexes from 0 to n-1 with at most jobs concurrent calls
+// The indexes are dispatched in order. After an error, the greater indexes are not dispatched anymore,
+// and the error of the lowest index is returned, so that the error does not depend on the scheduling
+func Run(n int, jobs int, fn func(i int) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > n {
+		jobs = n
+	}
+
+	var (
+		mu       sync.Mutex
+		next     int
+		errIndex = n
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	// dispatch returns the next index to process, or false if there is none
+	dispatch := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next >= n || next > errIndex {
+			return 0, false
+		}
+		i := next
+		next++
+		return i, true
+	}
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, ok := dispatch()
+				if !ok {
+					return
+				}
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if i < errIndex {
+						errIndex, firstErr = i, err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,63 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var running, maxRunning int32
+	results := make([]int, 100)
+
+	err := Run(len(results), 4, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for i, r := range results {
+		if r != i*i {
+			t.Errorf("Run() result %d = %d, want %d", i, r, i*i)
+		}
+	}
+	if maxRunning > 4 {
+		t.Errorf("Run() ran %d calls concurrently, want at most 4", maxRunning)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	// The error of the lowest index is returned, whatever the scheduling
+	for _, jobs := range []int{1, 2, 8, 32} {
+		for run := 0; run < 20; run++ {
+			err := Run(50, jobs, func(i int) error {
+				if i%10 == 7 {
+					// The later failures finish first
+					time.Sleep(time.Duration(50-i) * 10 * time.Microsecond)
+					return fmt.Errorf("file %d", i)
+				}
+				return nil
+			})
+			if err == nil || err.Error() != "file 7" {
+				t.Fatalf("Run() with %d jobs error = %v, want file 7", jobs, err)
+			}
+		}
+	}
+
+	if err := Run(0, 4, func(int) error { return errors.New("called") }); err != nil {
+		t.Errorf("Run() without index error = %v", err)
+	}
+}